@@ -0,0 +1,93 @@
+package ttlcache
+
+import "sort"
+
+type batchItem struct {
+	bidx uint64
+	hkey uint64
+	i    int
+}
+
+// Hash keys and sort them by buckets, so each bucket locks once per batch.
+func (c *cache[K, T]) batch(n int, keyfn func(i int) K) []batchItem {
+	items := make([]batchItem, n)
+	for i := 0; i < n; i++ {
+		hkey := c.conf.Hasher.Sum64(keyfn(i))
+		items[i] = batchItem{bidx: hkey % uint64(c.conf.Buckets), hkey: hkey, i: i}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].bidx < items[j].bidx })
+	return items
+}
+
+// Split sorted batch to per-bucket chunks and call fn for each of them.
+func (c *cache[K, T]) batchEach(items []batchItem, fn func(b *bucket[K, T], chunk []batchItem)) {
+	for lo := 0; lo < len(items); {
+		hi := lo + 1
+		for hi < len(items) && items[hi].bidx == items[lo].bidx {
+			hi++
+		}
+		fn(&c.buckets[items[lo].bidx], items[lo:hi])
+		lo = hi
+	}
+}
+
+// GetMany writes to dst all found entries. Missing and expired keys are skipped.
+func (c *cache[K, T]) GetMany(keys []K, dst map[K]T) error {
+	if err := c.checkCache(cacheStatusActive); err != nil {
+		return err
+	}
+	items := c.batch(len(keys), func(i int) K { return keys[i] })
+	c.batchEach(items, func(b *bucket[K, T], chunk []batchItem) {
+		now := b.clk().Now()
+		b.rlock()
+		defer b.runlock()
+		for _, itm := range chunk {
+			key := keys[itm.i]
+			if e, err := b.lookupLF(itm.hkey, key, now, b.mw()); err == nil {
+				dst[key] = e.payload
+			}
+		}
+	})
+	return nil
+}
+
+// SetMany sets all given entries. Returns first occurred error (if any), but tries to set remaining entries.
+func (c *cache[K, T]) SetMany(entries map[K]T) (err error) {
+	if err = c.checkCache(cacheStatusActive); err != nil {
+		return
+	}
+	keys := make([]K, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	items := c.batch(len(keys), func(i int) K { return keys[i] })
+	c.batchEach(items, func(b *bucket[K, T], chunk []batchItem) {
+		b.mux.Lock()
+		defer b.unlock()
+		for _, itm := range chunk {
+			key := keys[itm.i]
+			if err1 := b.setLF(itm.hkey, key, entries[key], -1, 0, b.mw()); err1 != nil && err == nil {
+				err = err1
+			}
+		}
+	})
+	return
+}
+
+// DeleteMany deletes all given keys. Returns first occurred error (if any), but tries to delete remaining keys.
+func (c *cache[K, T]) DeleteMany(keys []K) (err error) {
+	if err = c.checkCache(cacheStatusActive); err != nil {
+		return
+	}
+	items := c.batch(len(keys), func(i int) K { return keys[i] })
+	c.batchEach(items, func(b *bucket[K, T], chunk []batchItem) {
+		b.mux.Lock()
+		defer b.unlock()
+		for _, itm := range chunk {
+			if err1 := b.deleteLF(itm.hkey, keys[itm.i], b.mw()); err1 != nil && err == nil {
+				err = err1
+			}
+		}
+	})
+	return
+}
@@ -1,133 +1,534 @@
 package ttlcache
 
 import (
+	"context"
+	"math"
+	"math/rand/v2"
 	"sync"
+	"time"
 
 	"github.com/koykov/simd/memcpy"
 )
 
-type bucket[T any] struct {
-	conf *Config[T]
+type bucket[K comparable, T any] struct {
+	conf *KConfig[K, T]
 	id   string
 	size uint64
 	mux  sync.RWMutex
 	idx  map[uint64]uint
-	buf  []entry[T]
+	buf  []entry[K, T]
 	bbuf []byte
+	exp  expiry
+	tags tagIndex
+	stat bucketStats
+
+	// Eviction events collected under lock and dispatched after unlock.
+	evq *evictQueue[T]
+	evs []evictEvent[T]
+
+	// Eviction policy state. Read operations may touch it concurrently, thus it protects by separate mutex.
+	pmux sync.Mutex
+	pol  Policy
 
 	null T
 }
 
-func (b *bucket[T]) set(hkey uint64, value T) error {
+func (b *bucket[K, T]) set(ctx context.Context, hkey uint64, key K, value T, expire int64) error {
+	if err := b.lockCtx(ctx); err != nil {
+		return err
+	}
+	defer b.unlock()
+	return b.setLF(hkey, key, value, -1, expire, b.mwc(ctx))
+}
+
+// Set loaded entry and remember how long the loading took (see Config.EarlyRefresh). Refresh keeps tags of the entry.
+func (b *bucket[K, T]) setLoaded(ctx context.Context, hkey uint64, key K, value T, expire int64, delta time.Duration) error {
+	if err := b.lockCtx(ctx); err != nil {
+		return err
+	}
+	defer b.unlock()
+	var tags []string
+	if i, ok := b.idx[hkey]; ok && b.match(&b.buf[i], key) {
+		tags = b.buf[i].tags
+	}
+	if err := b.storeLF(hkey, key, value, -1, expire, b.mwc(ctx)); err != nil {
+		return err
+	}
+	b.tagLF(hkey, tags)
+	b.buf[b.idx[hkey]].delta = int64(delta)
+	return ErrOK
+}
+
+func (b *bucket[K, T]) setTagged(hkey uint64, key K, value T, tags []string) error {
+	b.mux.Lock()
+	defer b.unlock()
+	if err := b.setLF(hkey, key, value, -1, 0, b.mw()); err != nil {
+		return err
+	}
+	b.tagLF(hkey, tags)
+	return ErrOK
+}
+
+// Mark existing entry by tags.
+func (b *bucket[K, T]) tagLF(hkey uint64, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	e := &b.buf[b.idx[hkey]]
+	e.tags = append(e.tags[:0:0], tags...)
+	b.tags.add(hkey, e.tags)
+}
+
+// Delete all entries marked by the tag.
+func (b *bucket[K, T]) invalidate(tag string) int {
+	b.mux.Lock()
+	defer b.unlock()
+	set, ok := b.tags[tag]
+	if !ok {
+		return 0
+	}
+	hkeys := make([]uint64, 0, len(set))
+	for hkey := range set {
+		hkeys = append(hkeys, hkey)
+	}
+	for _, hkey := range hkeys {
+		if i, ok := b.idx[hkey]; ok {
+			b.evictLF(i, b.mw().Delete, EvictReasonDeleted)
+		}
+	}
+	return len(hkeys)
+}
+
+// Delete all entries matching fn.
+func (b *bucket[K, T]) deleteFn(fn func(e *entry[K, T]) bool) (c int) {
 	b.mux.Lock()
-	defer b.mux.Unlock()
-	return b.setLF(hkey, value, -1)
+	defer b.unlock()
+	// Iterate backward, since eviction moves the last entry to evicted position.
+	for i := len(b.buf) - 1; i >= 0; i-- {
+		if i < len(b.buf) && fn(&b.buf[i]) {
+			b.evictLF(uint(i), b.mw().Delete, EvictReasonDeleted)
+			c++
+		}
+	}
+	return
+}
+
+// Set negative entry, that marks the key as known missing.
+func (b *bucket[K, T]) setMissing(hkey uint64, key K, expire int64) error {
+	b.mux.Lock()
+	defer b.unlock()
+	if err := b.setLF(hkey, key, b.null, -1, expire, b.mw()); err != nil {
+		return err
+	}
+	b.buf[b.idx[hkey]].missing = true
+	return ErrOK
 }
 
-func (b *bucket[T]) setLF(hkey uint64, value T, timestamp int64) error {
-	if b.size > 0 && uint64(len(b.idx)) > b.size {
-		defer b.mw().Overflow(b.id)
-		return ErrOverflow
+// Set entry to the bucket and report replacement of existing entry.
+//
+// Negative timestamp means current time. Zero expire means that deadline calculates using config's TTL interval
+// considering jitter.
+func (b *bucket[K, T]) setLF(hkey uint64, key K, value T, timestamp, expire int64, mw MetricsWriter) error {
+	if i, ok := b.idx[hkey]; ok {
+		b.notifyLF(&b.buf[i], EvictReasonReplaced)
+	}
+	return b.storeLF(hkey, key, value, timestamp, expire, mw)
+}
+
+// Set entry to the bucket silently. Update and refresh keep the same logical entry (and often the same value), thus
+// replacement isn't reported.
+func (b *bucket[K, T]) storeLF(hkey uint64, key K, value T, timestamp, expire int64, mw MetricsWriter) error {
+	if b.idx == nil {
+		// Bucket closed, but background load may still finish after that.
+		return ErrCacheClosed
+	}
+	i, exists := b.idx[hkey]
+	if !exists && b.size > 0 && uint64(len(b.idx)) >= b.size {
+		if !b.evictVictimLF() {
+			b.stat.overflows.Add(1)
+			defer mw.Overflow(b.id)
+			return ErrOverflow
+		}
 	}
 
 	now := b.clk().Now()
-	defer b.mw().Set(b.id, b.clk().Now().Sub(now))
+	defer mw.Set(b.id, b.clk().Now().Sub(now))
 	if timestamp < 0 {
 		timestamp = now.UnixNano()
 	}
-	if i, ok := b.idx[hkey]; ok {
-		b.buf[i] = entry[T]{
+	if expire == 0 && b.conf.TTLInterval > 0 {
+		expire = timestamp + jitter(b.conf, int64(b.conf.TTLInterval))
+	}
+	var ttl int64
+	if expire > 0 {
+		ttl = expire - timestamp
+	}
+	if b.conf.StoreKeys {
+		// Key may point to reusable memory, so make a copy.
+		key = cloneKey(key)
+	} else {
+		var nk K
+		key = nk
+	}
+	if exists {
+		if b.conf.StoreKeys && b.buf[i].key != key {
+			// Newest entry wins, but collision must be reported.
+			mw.Collision(b.id)
+		}
+		b.tags.remove(hkey, b.buf[i].tags)
+		b.buf[i] = entry[K, T]{
 			payload:   value,
+			key:       key,
 			hkey:      hkey,
 			timestamp: timestamp,
+			expire:    expire,
+			ttl:       ttl,
+		}
+		b.exp.set(hkey, expire)
+		if b.pol != nil {
+			b.pol.Touch(hkey)
 		}
 		return ErrOK
 	}
-	b.buf = append(b.buf, entry[T]{
+	b.buf = append(b.buf, entry[K, T]{
 		payload:   value,
+		key:       key,
 		hkey:      hkey,
 		timestamp: timestamp,
+		expire:    expire,
+		ttl:       ttl,
 	})
 	b.idx[hkey] = uint(len(b.buf) - 1)
+	b.exp.set(hkey, expire)
+	if b.pol != nil {
+		b.pol.Add(hkey)
+	}
 	return ErrOK
 }
 
-func (b *bucket[T]) get(hkey uint64) (T, error) {
-	now := b.clk().Now()
-	b.mux.RLock()
-	defer b.mux.RUnlock()
+func (b *bucket[K, T]) update(hkey uint64, key K, fn UpdateFunc[T]) error {
+	now := b.clk().Now().UnixNano()
+	b.mux.Lock()
+	defer b.unlock()
 	var (
-		i  uint
-		ok bool
+		old       T
+		found     bool
+		timestamp int64 = -1
+		expire    int64
+		tags      []string
 	)
-	if i, ok = b.idx[hkey]; ok {
+	if i, ok := b.idx[hkey]; ok {
 		e := &b.buf[i]
-		deadline := now.Add(b.conf.TTLInterval).UnixNano()
-		if e.timestamp+deadline < now.UnixNano() {
-			b.mw().Expire(b.id)
-			return b.null, ErrExpire
+		if !b.match(e, key) {
+			b.mw().Collision(b.id)
+			return ErrCollision
 		}
-		b.mw().Hit(b.id, b.clk().Now().Sub(now))
-		return e.payload, nil
+		if !e.expired(now) && !e.missing {
+			old, found, timestamp, expire, tags = e.payload, true, e.timestamp, e.expire, e.tags
+		}
+	}
+	value, ok := fn(old, found)
+	if !ok {
+		return ErrOK
 	}
-	b.mw().Miss(b.id)
-	return b.null, ErrNotFound
+	if err := b.storeLF(hkey, key, value, timestamp, expire, b.mw()); err != nil {
+		return err
+	}
+	b.tagLF(hkey, tags)
+	return ErrOK
 }
 
-func (b *bucket[T]) delete(hkey uint64) error {
-	b.mux.Lock()
-	defer b.mux.Unlock()
-	if idx, ok := b.idx[hkey]; ok {
-		b.evictLF(idx, b.mw().Delete)
+func (b *bucket[K, T]) get(ctx context.Context, hkey uint64, key K) (T, error) {
+	now := b.clk().Now()
+	if err := b.rlockCtx(ctx); err != nil {
+		return b.null, err
 	}
-	return ErrOK
+	defer b.runlock()
+	e, err := b.lookupLF(hkey, key, now, b.mwc(ctx))
+	if err != nil {
+		return b.null, err
+	}
+	return e.payload, nil
 }
 
-func (b *bucket[T]) extract(hkey uint64) (T, error) {
+func (b *bucket[K, T]) getEntry(hkey uint64, key K) (EntryInfo[T], error) {
 	now := b.clk().Now()
-	b.mux.Lock()
-	defer b.mux.Unlock()
+	b.rlock()
+	defer b.runlock()
+	e, err := b.lookupLF(hkey, key, now, b.mw())
+	if err != nil {
+		return EntryInfo[T]{Value: b.null}, err
+	}
+	info := EntryInfo[T]{
+		Value:    e.payload,
+		Inserted: time.Unix(0, e.timestamp),
+	}
+	if exp := e.deadline(); exp > 0 {
+		info.ExpiresAt = time.Unix(0, exp)
+		info.TTL = info.ExpiresAt.Sub(now)
+	}
+	info.Refresh = b.refreshLF(e, now.UnixNano())
+	return info, nil
+}
+
+// Get the value and check if it requires early refresh.
+func (b *bucket[K, T]) getRefresh(ctx context.Context, hkey uint64, key K) (T, bool, error) {
+	now := b.clk().Now()
+	if err := b.rlockCtx(ctx); err != nil {
+		return b.null, false, err
+	}
+	defer b.runlock()
+	e, err := b.lookupLF(hkey, key, now, b.mwc(ctx))
+	if err != nil {
+		return b.null, false, err
+	}
+	return e.payload, b.refreshLF(e, now.UnixNano()), nil
+}
+
+// Check if entry requires early refresh using XFetch algorithm: probability of refresh grows as deadline approaches and
+// depends on how long the value took to load.
+func (b *bucket[K, T]) refreshLF(e *entry[K, T], now int64) bool {
+	exp := e.deadline()
+	if b.conf.EarlyRefresh <= 0 || e.delta <= 0 || exp <= 0 {
+		return false
+	}
+	gap := -float64(e.delta) * b.conf.EarlyRefresh * math.Log(1-rand.Float64())
+	return now+int64(gap) >= exp
+}
+
+// Get the value, considering grace period of expired entries. If refresh requested, stale entry marks as being
+// refreshed and refresh flag reports whether caller should load it (see Config.RefreshBackoff).
+func (b *bucket[K, T]) getStale(hkey uint64, key K, refresh bool) (v T, stale, load bool, err error) {
+	now := b.clk().Now()
+	b.rlock()
+	defer b.runlock()
+	e, err := b.lookupLF(hkey, key, now, b.mw())
+	if err == nil {
+		return e.payload, false, false, nil
+	}
+	if err != ErrExpire || b.conf.StaleTTL <= 0 {
+		return b.null, false, false, err
+	}
+	e = &b.buf[b.idx[hkey]]
+	if e.missing || e.deadline()+int64(b.conf.StaleTTL) < now.UnixNano() {
+		return b.null, false, false, ErrExpire
+	}
+	if refresh {
+		load = e.markRefresh(now.UnixNano(), int64(b.conf.RefreshBackoff))
+	}
+	return e.payload, true, load, nil
+}
+
+// Find live entry. Returned pointer is valid only until bucket unlock.
+func (b *bucket[K, T]) lookupLF(hkey uint64, key K, now time.Time, mw MetricsWriter) (*entry[K, T], error) {
 	var (
 		i  uint
 		ok bool
 	)
 	if i, ok = b.idx[hkey]; ok {
 		e := &b.buf[i]
-		deadline := now.Add(b.conf.TTLInterval).UnixNano()
-		if e.timestamp+deadline < now.UnixNano() {
-			b.mw().Expire(b.id)
-			return b.null, ErrExpire
+		if !b.match(e, key) {
+			mw.Collision(b.id)
+			return nil, ErrCollision
+		}
+		if e.expired(now.UnixNano()) {
+			b.stat.expires.Add(1)
+			mw.Expire(b.id)
+			return nil, ErrExpire
+		}
+		if e.missing {
+			b.touch(hkey)
+			b.stat.cachedMisses.Add(1)
+			mw.CachedMiss(b.id)
+			return nil, ErrCachedMiss
 		}
-		b.mw().Hit(b.id, b.clk().Now().Sub(now))
-		b.evictLF(i, b.mw().Extract)
-		return e.payload, nil
+		b.touch(hkey)
+		if b.conf.Sliding {
+			b.slideLF(e, now.UnixNano(), e.ttl)
+		}
+		b.stat.hits.Add(1)
+		mw.Hit(b.id, b.clk().Now().Sub(now))
+		return e, nil
 	}
-	b.mw().Miss(b.id)
-	return b.null, ErrNotFound
+	b.touch(hkey)
+	b.stat.misses.Add(1)
+	mw.Miss(b.id)
+	return nil, ErrNotFound
 }
 
-func (b *bucket[T]) evict() error {
+// Move entry's deadline forward. Zero ttl means entry's own TTL.
+func (b *bucket[K, T]) slide(hkey uint64, key K, ttl time.Duration) error {
 	now := b.clk().Now().UnixNano()
-	var c int
 	b.mux.Lock()
+	defer b.unlock()
+	i, ok := b.idx[hkey]
+	if !ok {
+		return ErrNotFound
+	}
+	e := &b.buf[i]
+	if !b.match(e, key) {
+		b.mw().Collision(b.id)
+		return ErrCollision
+	}
+	if e.expired(now) {
+		return ErrExpire
+	}
+	if ttl > 0 {
+		e.ttl = int64(ttl)
+	}
+	if e.ttl > 0 {
+		e.expire = now + e.ttl
+		b.exp.set(e.hkey, e.expire)
+	}
+	return ErrOK
+}
+
+// Move entry's deadline forward on read. Works under read lock, thus expiration index isn't updated here, but
+// eviction checks actual deadlines (see nextExpiredLF).
+func (b *bucket[K, T]) slideLF(e *entry[K, T], now, ttl int64) {
+	if ttl <= 0 {
+		return
+	}
+	e.prolong(now + ttl)
+}
+
+func (b *bucket[K, T]) delete(ctx context.Context, hkey uint64, key K) error {
+	if err := b.lockCtx(ctx); err != nil {
+		return err
+	}
+	defer b.unlock()
+	return b.deleteLF(hkey, key, b.mwc(ctx))
+}
+
+func (b *bucket[K, T]) deleteLF(hkey uint64, key K, mw MetricsWriter) error {
+	if idx, ok := b.idx[hkey]; ok {
+		if !b.match(&b.buf[idx], key) {
+			mw.Collision(b.id)
+			return ErrCollision
+		}
+		b.evictLF(idx, mw.Delete, EvictReasonDeleted)
+	}
+	return ErrOK
+}
+
+func (b *bucket[K, T]) extract(ctx context.Context, hkey uint64, key K) (T, error) {
+	now := b.clk().Now()
+	if err := b.lockCtx(ctx); err != nil {
+		return b.null, err
+	}
+	defer b.unlock()
+	mw := b.mwc(ctx)
+	e, err := b.lookupLF(hkey, key, now, mw)
+	if err != nil {
+		return b.null, err
+	}
+	payload := e.payload
+	b.evictLF(b.idx[hkey], mw.Extract, EvictReasonExtracted)
+	return payload, nil
+}
+
+func (b *bucket[K, T]) evict() error {
+	var c int
 	defer func() {
 		if b.l() != nil {
 			b.l().Printf("bucket #%s: evict %d entries", b.id, c)
 		}
-		b.mux.Unlock()
 	}()
-	for i := 0; i < len(b.buf); i++ {
-		if now-b.buf[i].timestamp > int64(b.conf.TTLInterval) {
-			b.evictLF(uint(i), b.mw().Evict)
-			c++
+	// Evict by short slices to avoid long lock of the bucket.
+	for {
+		now := b.clk().Now().UnixNano()
+		b.mux.Lock()
+		n := b.evictExpiredLF(now, evictSliceSize)
+		b.unlock()
+		c += n
+		if n < evictSliceSize {
+			break
 		}
 	}
 	return ErrOK
 }
 
-func (b *bucket[T]) evictLF(idx uint, metricfn func(string)) {
+// Evict up to limit expired entries using expiration index.
+func (b *bucket[K, T]) evictExpiredLF(now int64, limit int) (c int) {
+	for c < limit {
+		i, ok := b.nextExpiredLF(now - int64(b.conf.StaleTTL))
+		if !ok {
+			break
+		}
+		b.stat.evicts.Add(1)
+		b.evictLF(i, b.mw().Evict, EvictReasonExpired)
+		c++
+	}
+	return
+}
+
+// Find entry with the nearest deadline before now. Deadlines moved by sliding expiration are synced to expiration
+// index on the way.
+func (b *bucket[K, T]) nextExpiredLF(now int64) (uint, bool) {
+	for {
+		itm, ok := b.exp.peek()
+		if !ok || itm.expire >= now {
+			return 0, false
+		}
+		i, ok := b.idx[itm.hkey]
+		if !ok {
+			b.exp.remove(itm.hkey)
+			continue
+		}
+		if exp := b.buf[i].deadline(); exp > itm.expire {
+			b.exp.set(itm.hkey, exp)
+			continue
+		}
+		return i, true
+	}
+}
+
+// Evict victim to free space. Already expired entry goes first, otherwise victim chosen by eviction policy.
+func (b *bucket[K, T]) evictVictimLF() bool {
+	if i, ok := b.nextExpiredLF(b.clk().Now().UnixNano()); ok {
+		b.stat.evicts.Add(1)
+		b.evictLF(i, b.mw().Evict, EvictReasonExpired)
+		return true
+	}
+	if b.pol == nil {
+		return false
+	}
+	for {
+		hkey, ok := b.pol.Victim()
+		if !ok {
+			return false
+		}
+		if a, ok := b.pol.(Admitter); ok {
+			if cand, ok := a.Candidate(); ok && cand != hkey {
+				if a.Admit(cand, hkey) {
+					b.mw().Admit(b.id)
+				} else {
+					b.mw().Reject(b.id)
+					hkey = cand
+				}
+			}
+		}
+		i, ok := b.idx[hkey]
+		if !ok {
+			// Policy is out of sync with the bucket, so just forget the key and try the next victim.
+			b.pol.Remove(hkey)
+			continue
+		}
+		b.stat.evicts.Add(1)
+		b.evictLF(i, b.mw().Evict, EvictReasonCapacity)
+		return true
+	}
+}
+
+func (b *bucket[K, T]) evictLF(idx uint, metricfn func(string), reason EvictReason) {
 	l := len(b.buf)
 	oldHK := b.buf[idx].hkey
+	b.notifyLF(&b.buf[idx], reason)
+	b.exp.remove(oldHK)
+	b.tags.remove(oldHK, b.buf[idx].tags)
+	if b.pol != nil {
+		b.pol.Remove(oldHK)
+	}
 	if idx == uint(l-1) {
 		// Edge case: evict last item.
 		b.buf = b.buf[:l-1]
@@ -145,16 +546,40 @@ func (b *bucket[T]) evictLF(idx uint, metricfn func(string)) {
 	metricfn(b.id)
 }
 
-func (b *bucket[T]) dump() (err error) {
+func (b *bucket[K, T]) len() int {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return len(b.buf)
+}
+
+// Append live entries to dst. Produces consistent snapshot of the bucket.
+func (b *bucket[K, T]) snapshot(dst []entry[K, T]) []entry[K, T] {
+	now := b.clk().Now().UnixNano()
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	for i := 0; i < len(b.buf); i++ {
+		if e := &b.buf[i]; !e.expired(now) && !e.missing {
+			dst = append(dst, e.copy())
+		}
+	}
+	return dst
+}
+
+func (b *bucket[K, T]) dump() (err error) {
 	b.mux.RLock()
 	defer b.mux.RUnlock()
 	for i := 0; i < len(b.buf); i++ {
-		e := b.buf[i]
+		e := &b.buf[i]
+		if e.missing {
+			// Negative entries have no payload to encode.
+			continue
+		}
 		b.bbuf, _, _ = b.conf.DumpEncoder.Encode(b.bbuf[:0], e.payload)
 		oe := Entry{
 			Key:    e.hkey,
 			Body:   make([]byte, len(b.bbuf)),
-			Expire: uint32(e.timestamp),
+			Expire: e.deadline(),
+			Tags:   e.tags,
 		}
 		memcpy.Copy(oe.Body, b.bbuf)
 		if _, err = b.conf.DumpWriter.Write(oe); err != nil {
@@ -165,43 +590,191 @@ func (b *bucket[T]) dump() (err error) {
 	return
 }
 
-func (b *bucket[T]) reset() error {
+func (b *bucket[K, T]) reset() error {
 	b.mux.Lock()
-	defer b.mux.Unlock()
-	b.buf = b.buf[:0]
+	buf := b.buf
+	defer func() {
+		b.unlock()
+		b.notifyAll(buf, EvictReasonReset)
+	}()
+	if b.evq != nil {
+		// Entries are detached to send events outside the lock.
+		b.buf = nil
+	} else {
+		b.buf = b.buf[:0]
+	}
 	for k := range b.idx {
 		delete(b.idx, k)
 	}
+	b.exp.reset()
+	b.tags.reset()
+	if b.pol != nil {
+		b.pol.Reset()
+	}
 	return ErrOK
 }
 
-func (b *bucket[T]) close() error {
+func (b *bucket[K, T]) close() error {
 	b.mux.Lock()
-	defer b.mux.Unlock()
-	for _, v := range b.idx {
-		_ = v
+	buf := b.buf
+	defer func() {
+		b.unlock()
+		b.notifyAll(buf, EvictReasonClosed)
+	}()
+	for i := 0; i < len(b.buf); i++ {
 		b.mw().Delete(b.id)
 	}
-	b.buf, b.idx = nil, nil
+	b.buf, b.idx, b.pol, b.tags = nil, nil, nil, nil
+	b.exp = expiry{}
 	return ErrOK
 }
 
-func (b *bucket[T]) svcLock() {
+// Register access to the key in eviction policy.
+func (b *bucket[K, T]) touch(hkey uint64) {
+	if b.pol == nil {
+		return
+	}
+	b.pmux.Lock()
+	b.pol.Touch(hkey)
+	b.pmux.Unlock()
+}
+
+func (b *bucket[K, T]) rlock() {
+	b.mux.RLock()
+}
+
+// Lock the bucket for writing. Gives up when ctx is done.
+func (b *bucket[K, T]) lockCtx(ctx context.Context) error {
+	return b.waitLock(ctx, true)
+}
+
+// Lock the bucket for reading. Gives up when ctx is done.
+func (b *bucket[K, T]) rlockCtx(ctx context.Context) error {
+	return b.waitLock(ctx, false)
+}
+
+// Acquire the lock. Context that never done (e.g. context.Background()) waits as usual, otherwise lock acquires in
+// separate goroutine, so waiting for the lock stays in the mutex queue and may be abandoned when ctx is done.
+func (b *bucket[K, T]) waitLock(ctx context.Context, write bool) error {
+	done := ctx.Done()
+	if done == nil {
+		b.lock(write)
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if b.tryLock(write) {
+		return nil
+	}
+	locked := make(chan struct{})
+	go func() {
+		b.lock(write)
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return nil
+	case <-done:
+		// Release the lock as soon as it will be acquired.
+		go func() {
+			<-locked
+			if write {
+				b.mux.Unlock()
+			} else {
+				b.mux.RUnlock()
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
+func (b *bucket[K, T]) lock(write bool) {
+	if write {
+		b.mux.Lock()
+		return
+	}
+	b.mux.RLock()
+}
+
+func (b *bucket[K, T]) tryLock(write bool) bool {
+	if write {
+		return b.mux.TryLock()
+	}
+	return b.mux.TryRLock()
+}
+
+func (b *bucket[K, T]) runlock() {
+	b.mux.RUnlock()
+}
+
+// Check if entry belongs to the key. Always true if keys storing disabled.
+func (b *bucket[K, T]) match(e *entry[K, T], key K) bool {
+	return !b.conf.StoreKeys || e.key == key
+}
+
+func (b *bucket[K, T]) svcLock() {
 	b.mux.Lock()
 }
 
-func (b *bucket[T]) svcUnlock() {
+func (b *bucket[K, T]) svcUnlock() {
+	b.unlock()
+}
+
+// Register eviction event of the entry. Negative entries have no value, thus produce no events.
+func (b *bucket[K, T]) notifyLF(e *entry[K, T], reason EvictReason) {
+	if b.evq == nil || e.missing {
+		return
+	}
+	b.evs = append(b.evs, evictEvent[T]{hkey: e.hkey, payload: e.payload, reason: reason})
+}
+
+// Unlock the bucket and dispatch collected eviction events outside the lock.
+func (b *bucket[K, T]) unlock() {
+	evs := b.evs
+	b.evs = nil
 	b.mux.Unlock()
+	for i := 0; i < len(evs); i++ {
+		b.dispatch(evs[i])
+	}
 }
 
-func (b *bucket[T]) mw() MetricsWriter {
+// Dispatch eviction events of detached entries. Must be called outside the lock.
+func (b *bucket[K, T]) notifyAll(buf []entry[K, T], reason EvictReason) {
+	if b.evq == nil {
+		return
+	}
+	for i := 0; i < len(buf); i++ {
+		if buf[i].missing {
+			continue
+		}
+		b.dispatch(evictEvent[T]{hkey: buf[i].hkey, payload: buf[i].payload, reason: reason})
+	}
+}
+
+func (b *bucket[K, T]) dispatch(e evictEvent[T]) {
+	if !b.evq.push(e) {
+		b.stat.evictDrops.Add(1)
+		b.mw().EvictDrop(b.id)
+	}
+}
+
+func (b *bucket[K, T]) mw() MetricsWriter {
+	return b.conf.MetricsWriter
+}
+
+// Get metrics writer bound to the context (see ContextMetricsWriter).
+func (b *bucket[K, T]) mwc(ctx context.Context) MetricsWriter {
+	if cmw, ok := b.conf.MetricsWriter.(ContextMetricsWriter); ok {
+		return cmw.WithContext(ctx)
+	}
 	return b.conf.MetricsWriter
 }
 
-func (b *bucket[T]) clk() Clock {
+func (b *bucket[K, T]) clk() Clock {
 	return b.conf.Clock
 }
 
-func (b *bucket[T]) l() Logger {
+func (b *bucket[K, T]) l() Logger {
 	return b.conf.Logger
 }
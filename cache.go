@@ -1,7 +1,9 @@
 package ttlcache
 
 import (
+	"context"
 	"io"
+	"iter"
 	"reflect"
 	"strconv"
 	"sync"
@@ -9,24 +11,61 @@ import (
 	"time"
 )
 
-type Cache[T any] interface {
-	Set(key string, value T) error
-	Get(key string) (T, error)
-	Delete(key string) error
-	Extract(key string) (T, error)
+// KCache is a cache with keys of any comparable type K.
+type KCache[K comparable, T any] interface {
+	Set(key K, value T) error
+	SetWithTTL(key K, value T, ttl time.Duration) error
+	SetExpireAt(key K, value T, expireAt time.Time) error
+	SetMissing(key K, ttl time.Duration) error
+	SetWithTags(key K, value T, tags ...string) error
+	Get(key K) (T, error)
+	GetEntry(key K) (EntryInfo[T], error)
+	GetOrLoad(ctx context.Context, key K, loader LoadFunc[T]) (T, error)
+	GetStale(key K) (T, bool, error)
+	Delete(key K) error
+	Extract(key K) (T, error)
+	SetCtx(ctx context.Context, key K, value T) error
+	SetWithTTLCtx(ctx context.Context, key K, value T, ttl time.Duration) error
+	GetCtx(ctx context.Context, key K) (T, error)
+	DeleteCtx(ctx context.Context, key K) error
+	ExtractCtx(ctx context.Context, key K) (T, error)
+	GetMany(keys []K, dst map[K]T) error
+	SetMany(entries map[K]T) error
+	DeleteMany(keys []K) error
+	InvalidateTag(tag string) (int, error)
+	DeletePrefix(prefix string) (int, error)
+	Update(key K, fn UpdateFunc[T]) error
+	Touch(key K) error
+	TouchWithTTL(key K, ttl time.Duration) error
+	SetIfAbsent(key K, value T) (T, bool, error)
+	Range(fn func(key uint64, value T, expiresAt time.Time) bool) error
+	All() iter.Seq2[uint64, T]
+	Len() int
+	BucketLens() []int
+	Stats() Stats
 	Close() error
 	Reset() error
 }
 
-type cache[T any] struct {
+// Cache is a cache with string keys.
+type Cache[T any] = KCache[string, T]
+
+type cache[K comparable, T any] struct {
 	status  uint32
-	conf    *Config[T]
-	buckets []bucket[T]
+	conf    *KConfig[K, T]
+	buckets []bucket[K, T]
+	flight  flight[K, T]
+	evq     *evictQueue[T]
 	null    T
 }
 
 func New[T any](conf *Config[T]) (Cache[T], error) {
-	c := &cache[T]{
+	return NewK[string, T](conf)
+}
+
+// NewK makes cache with keys of type K. Config.Hasher must be able to hash K (see IntHasher for integer keys).
+func NewK[K comparable, T any](conf *KConfig[K, T]) (KCache[K, T], error) {
+	c := &cache[K, T]{
 		status: cacheStatusActive,
 		conf:   conf.Copy(),
 	}
@@ -38,81 +77,260 @@ func New[T any](conf *Config[T]) (Cache[T], error) {
 	return c, nil
 }
 
-func (c *cache[T]) Set(key string, value T) error {
+func (c *cache[K, T]) Set(key K, value T) error {
+	return c.set(context.Background(), key, value, 0)
+}
+
+func (c *cache[K, T]) SetWithTTL(key K, value T, ttl time.Duration) error {
+	return c.set(context.Background(), key, value, c.deadline(ttl))
+}
+
+// SetExpireAt sets the value with absolute deadline. Zero expireAt means default TTL, past time means that entry is
+// already expired.
+func (c *cache[K, T]) SetExpireAt(key K, value T, expireAt time.Time) error {
+	var expire int64
+	if !expireAt.IsZero() {
+		// Deadlines before the epoch are negative, but still in the past.
+		expire = max(expireAt.UnixNano(), 1)
+	}
+	return c.set(context.Background(), key, value, expire)
+}
+
+// SetMissing caches the key as missing (negative entry), so Get returns ErrCachedMiss until entry expires.
+func (c *cache[K, T]) SetMissing(key K, ttl time.Duration) error {
 	if err := c.checkCache(cacheStatusActive); err != nil {
 		return err
 	}
 	hkey := c.conf.Hasher.Sum64(key)
 	b := &c.buckets[hkey%uint64(c.conf.Buckets)]
-	return b.set(hkey, value)
+	return b.setMissing(hkey, key, c.deadline(ttl))
 }
 
-func (c *cache[T]) Get(key string) (T, error) {
+func (c *cache[K, T]) set(ctx context.Context, key K, value T, expire int64) error {
 	if err := c.checkCache(cacheStatusActive); err != nil {
-		return c.null, err
+		return err
 	}
 	hkey := c.conf.Hasher.Sum64(key)
 	b := &c.buckets[hkey%uint64(c.conf.Buckets)]
-	return b.get(hkey)
+	return b.set(ctx, hkey, key, value, expire)
+}
+
+func (c *cache[K, T]) Get(key K) (T, error) {
+	return c.GetCtx(context.Background(), key)
 }
 
-func (c *cache[T]) Delete(key string) error {
+// GetEntry returns the value with its expiration metadata.
+func (c *cache[K, T]) GetEntry(key K) (EntryInfo[T], error) {
 	if err := c.checkCache(cacheStatusActive); err != nil {
-		return err
+		return EntryInfo[T]{Value: c.null}, err
 	}
 	hkey := c.conf.Hasher.Sum64(key)
 	b := &c.buckets[hkey%uint64(c.conf.Buckets)]
-	return b.delete(hkey)
+	return b.getEntry(hkey, key)
 }
 
-func (c *cache[T]) Extract(key string) (T, error) {
+func (c *cache[K, T]) GetOrLoad(ctx context.Context, key K, loader LoadFunc[T]) (T, error) {
 	if err := c.checkCache(cacheStatusActive); err != nil {
 		return c.null, err
 	}
 	hkey := c.conf.Hasher.Sum64(key)
 	b := &c.buckets[hkey%uint64(c.conf.Buckets)]
-	return b.extract(hkey)
+	v, refresh, err := b.getRefresh(ctx, hkey, key)
+	if err == nil && refresh {
+		// Early refresh requested, so reload the value before real deadline.
+		return c.fetch(ctx, b, hkey, key, loader)
+	}
+	if err != ErrNotFound && err != ErrExpire {
+		return v, err
+	}
+	return c.fetch(ctx, b, hkey, key, loader)
+}
+
+// GetStale returns the value even if it expired, but only during grace period (see Config.StaleTTL). Stale flag
+// reports whether value expired. Stale entries refresh in background if Config.Loader provided, but not more often than
+// Config.RefreshBackoff.
+func (c *cache[K, T]) GetStale(key K) (T, bool, error) {
+	if err := c.checkCache(cacheStatusActive); err != nil {
+		return c.null, false, err
+	}
+	hkey := c.conf.Hasher.Sum64(key)
+	b := &c.buckets[hkey%uint64(c.conf.Buckets)]
+	v, stale, refresh, err := b.getStale(hkey, key, c.conf.Loader != nil)
+	if refresh {
+		key = cloneKey(key)
+		go func() {
+			_, _ = c.fetch(context.Background(), b, hkey, key, func(ctx context.Context) (T, time.Duration, error) {
+				return c.conf.Loader(ctx, key)
+			})
+		}()
+	}
+	return v, stale, err
+}
+
+// Load the value using loader once per key and store it to the bucket. If loader fails, old value returns (if
+// still available or stale).
+func (c *cache[K, T]) fetch(ctx context.Context, b *bucket[K, T], hkey uint64, key K, loader LoadFunc[T]) (T, error) {
+	// Load may outlive the caller, so the key must not point to caller's memory.
+	key = cloneKey(key)
+	fk := flightKey[K]{hkey: hkey}
+	if c.conf.StoreKeys {
+		fk.key = key
+	}
+	return c.flight.do(ctx, fk, func(ctx context.Context) (T, error) {
+		start := c.conf.Clock.Now()
+		v, ttl, err := loader(ctx)
+		if err != nil {
+			if sv, _, _, err1 := b.getStale(hkey, key, false); err1 == nil {
+				return sv, nil
+			}
+			return v, err
+		}
+		// Loaded value returns to caller even if it can't be stored (overflow).
+		_ = b.setLoaded(ctx, hkey, key, v, c.deadline(ttl), c.conf.Clock.Now().Sub(start))
+		return v, nil
+	})
+}
+
+// Touch moves entry's deadline forward by its TTL.
+func (c *cache[K, T]) Touch(key K) error {
+	return c.TouchWithTTL(key, 0)
+}
+
+// TouchWithTTL sets new TTL of the entry starting from now.
+func (c *cache[K, T]) TouchWithTTL(key K, ttl time.Duration) error {
+	if err := c.checkCache(cacheStatusActive); err != nil {
+		return err
+	}
+	hkey := c.conf.Hasher.Sum64(key)
+	b := &c.buckets[hkey%uint64(c.conf.Buckets)]
+	return b.slide(hkey, key, ttl)
+}
+
+func (c *cache[K, T]) Delete(key K) error {
+	return c.DeleteCtx(context.Background(), key)
 }
 
-func (c *cache[T]) Close() error {
+func (c *cache[K, T]) Extract(key K) (T, error) {
+	return c.ExtractCtx(context.Background(), key)
+}
+
+// Range calls fn for each live entry. Iterates bucket by bucket using per-bucket snapshots, thus fn may safely call
+// cache methods. Iteration stops if fn returns false. Zero expiresAt means entry never expires.
+func (c *cache[K, T]) Range(fn func(key uint64, value T, expiresAt time.Time) bool) error {
+	if err := c.checkCache(cacheStatusActive); err != nil {
+		return err
+	}
+	var buf []entry[K, T]
+	for i := 0; i < len(c.buckets); i++ {
+		buf = c.buckets[i].snapshot(buf[:0])
+		for j := 0; j < len(buf); j++ {
+			e := &buf[j]
+			var exp time.Time
+			if e.expire > 0 {
+				exp = time.Unix(0, e.expire)
+			}
+			if !fn(e.hkey, e.payload, exp) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// All returns iterator over all live entries. See Range for details.
+func (c *cache[K, T]) All() iter.Seq2[uint64, T] {
+	return func(yield func(uint64, T) bool) {
+		_ = c.Range(func(key uint64, value T, _ time.Time) bool {
+			return yield(key, value)
+		})
+	}
+}
+
+// Len returns total number of entries, including expired but not yet evicted.
+func (c *cache[K, T]) Len() (n int) {
+	for i := 0; i < len(c.buckets); i++ {
+		n += c.buckets[i].len()
+	}
+	return
+}
+
+// BucketLens returns number of entries in each bucket.
+func (c *cache[K, T]) BucketLens() []int {
+	r := make([]int, len(c.buckets))
+	for i := 0; i < len(c.buckets); i++ {
+		r[i] = c.buckets[i].len()
+	}
+	return r
+}
+
+func (c *cache[K, T]) Stats() (s Stats) {
+	s.Size = c.conf.Size
+	for i := 0; i < len(c.buckets); i++ {
+		b := &c.buckets[i]
+		s.Len += uint64(b.len())
+		b.stat.appendTo(&s)
+	}
+	if s.Size > 0 {
+		s.FillRatio = float64(s.Len) / float64(s.Size)
+	}
+	return
+}
+
+func (c *cache[K, T]) Close() error {
 	atomic.StoreUint32(&c.status, cacheStatusClosed)
 	c.conf.Clock.Stop()
-	return c.bulkClose()
+	err := c.bulkClose()
+	if c.evq != nil {
+		c.evq.close()
+	}
+	return err
 }
 
-func (c *cache[T]) Reset() error {
+func (c *cache[K, T]) Reset() error {
 	return c.bulkReset()
 }
 
-func (c *cache[T]) bulkEvict() error {
-	return c.bulkExec(c.conf.EvictWorkers, "eviction", func(b *bucket[T]) error {
+func (c *cache[K, T]) bulkEvict() error {
+	return c.bulkExec(c.conf.EvictWorkers, "eviction", func(b *bucket[K, T]) error {
 		return b.evict()
 	})
 }
 
-func (c *cache[T]) bulkClose() error {
-	return c.bulkExec(c.conf.EvictWorkers, "close", func(b *bucket[T]) error {
+func (c *cache[K, T]) bulkClose() error {
+	return c.bulkExec(c.conf.EvictWorkers, "close", func(b *bucket[K, T]) error {
 		return b.close()
 	})
 }
 
-func (c *cache[T]) bulkReset() error {
-	return c.bulkExec(c.conf.EvictWorkers, "reset", func(b *bucket[T]) error {
+func (c *cache[K, T]) bulkReset() error {
+	return c.bulkExec(c.conf.EvictWorkers, "reset", func(b *bucket[K, T]) error {
 		return b.reset()
 	})
 }
 
-func (c *cache[T]) dump() error {
+func (c *cache[K, T]) dump() error {
 	if c.conf.DumpWriter == nil {
 		return ErrOK
 	}
-	if err := c.bulkExec(c.conf.DumpWriteWorkers, "dump", func(b *bucket[T]) error { return b.dump() }); err != nil {
+	if err := c.bulkExec(c.conf.DumpWriteWorkers, "dump", func(b *bucket[K, T]) error { return b.dump() }); err != nil {
 		return err
 	}
 	return c.conf.DumpWriter.Flush()
 }
 
-func (c *cache[T]) load() (int, error) {
+// Load entries from dump. Expired entries are skipped, live entries keep their deadlines.
+func (c *cache[K, T]) load() (r loadReport, err error) {
+	var (
+		loaded, skipped, failed atomic.Uint64
+		once                    sync.Once
+		ferr                    error
+	)
+	fail := func(bkt *bucket[K, T], err error) {
+		failed.Add(1)
+		c.mw().LoadFail(bkt.id)
+		once.Do(func() { ferr = err })
+	}
+
 	stream := make(chan Entry, c.conf.DumpReadBuffer)
 	var wg sync.WaitGroup
 	for i := uint(0); i < c.conf.DumpReadWorkers; i++ {
@@ -126,40 +344,67 @@ func (c *cache[T]) load() (int, error) {
 						return
 					}
 					bkt := &c.buckets[e.Key%uint64(c.conf.Buckets)]
+					if e.Expire > 0 && e.Expire <= c.conf.Clock.Now().UnixNano() {
+						// Entry expired while cache was down.
+						skipped.Add(1)
+						c.mw().LoadSkip(bkt.id)
+						continue
+					}
 					var t T
 					t = c.ensureValue(t)
 					if err := c.conf.DumpDecoder.Decode(&t, e.Body); err != nil {
+						fail(bkt, err)
 						continue
 					}
 					bkt.svcLock()
-					_ = bkt.setLF(e.Key, t, int64(e.Expire))
+					var nk K
+					err := bkt.setLF(e.Key, nk, t, -1, e.Expire, c.mw())
+					if err == nil {
+						bkt.tagLF(e.Key, e.Tags)
+					}
 					bkt.svcUnlock()
+					if err != nil {
+						fail(bkt, err)
+						continue
+					}
+					loaded.Add(1)
 					c.mw().Load(bkt.id)
 				}
 			}
 		}()
 	}
 
-	var lc int
 	for {
-		e, err := c.conf.DumpReader.Read()
-		if err != nil {
+		e, err1 := c.conf.DumpReader.Read()
+		if err1 != nil {
 			close(stream)
-			if err != io.EOF && c.l() != nil {
-				c.l().Printf("dump load interrupt due to error: %s", err.Error())
+			if err1 != io.EOF {
+				err = err1
 			}
 			break
 		}
 		stream <- e
-		lc++
+		r.read++
 	}
 
 	wg.Wait()
 
-	return lc, nil
+	r.loaded, r.skipped, r.failed, r.ferr = loaded.Load(), skipped.Load(), failed.Load(), ferr
+	if sr, ok := c.conf.DumpReader.(interface{ Skipped() uint64 }); ok {
+		r.corrupted = sr.Skipped()
+	}
+	return
 }
 
-func (c *cache[T]) bulkExec(workers uint, op string, fn func(b *bucket[T]) error) error {
+// Counters of dump load.
+type loadReport struct {
+	read                               int
+	loaded, skipped, failed, corrupted uint64
+	// First failure.
+	ferr error
+}
+
+func (c *cache[K, T]) bulkExec(workers uint, op string, fn func(b *bucket[K, T]) error) error {
 	if workers == 0 || workers > c.conf.Buckets {
 		workers = c.conf.Buckets
 	}
@@ -196,7 +441,15 @@ func (c *cache[T]) bulkExec(workers uint, op string, fn func(b *bucket[T]) error
 	return nil
 }
 
-func (c *cache[T]) checkCache(allow uint32) error {
+// Get absolute expiration time for given TTL considering jitter. Zero TTL means default TTL interval.
+func (c *cache[K, T]) deadline(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return c.conf.Clock.Now().UnixNano() + jitter(c.conf, int64(ttl))
+}
+
+func (c *cache[K, T]) checkCache(allow uint32) error {
 	if status := atomic.LoadUint32(&c.status); status&allow == 0 {
 		if status == cacheStatusNil {
 			return ErrBadCache
@@ -208,15 +461,15 @@ func (c *cache[T]) checkCache(allow uint32) error {
 	return nil
 }
 
-func (c *cache[T]) mw() MetricsWriter {
+func (c *cache[K, T]) mw() MetricsWriter {
 	return c.conf.MetricsWriter
 }
 
-func (c *cache[T]) l() Logger {
+func (c *cache[K, T]) l() Logger {
 	return c.conf.Logger
 }
 
-func (c *cache[T]) init() error {
+func (c *cache[K, T]) init() error {
 	if c.conf == nil {
 		return ErrNoConfig
 	}
@@ -226,10 +479,25 @@ func (c *cache[T]) init() error {
 	if c.conf.Buckets == 0 {
 		return ErrNoBuckets
 	}
+	if c.conf.StoreKeys && (c.conf.DumpWriter != nil || c.conf.DumpReader != nil) {
+		return ErrDumpKeys
+	}
+	// Validate TTL before any goroutine (clock, evict queue) starts.
+	if c.conf.TTLInterval > 0 {
+		if c.conf.TTLInterval < time.Second {
+			return ErrShortTTL
+		}
+		if c.conf.EvictInterval == 0 {
+			c.conf.EvictInterval = c.conf.TTLInterval / 2
+		}
+	}
 
 	if c.conf.MetricsWriter == nil {
 		c.conf.MetricsWriter = dummyMW{}
 	}
+	if c.conf.RefreshBackoff == 0 {
+		c.conf.RefreshBackoff = defaultRefreshBackoff
+	}
 
 	if c.conf.Clock == nil {
 		c.conf.Clock = &NativeClock{}
@@ -238,28 +506,34 @@ func (c *cache[T]) init() error {
 		c.conf.Clock.Start()
 	}
 
+	if c.conf.OnEvict != nil {
+		if c.conf.OnEvictQueueSize == 0 {
+			c.conf.OnEvictQueueSize = defaultOnEvictQueueSize
+		}
+		c.evq = newEvictQueue[T](c.conf.OnEvict, c.conf.OnEvictQueueSize)
+	}
+
 	var bsize uint64
 	if c.conf.Size > 0 {
 		bsize = c.conf.Size / uint64(c.conf.Buckets)
 	}
-	c.buckets = make([]bucket[T], 0, c.conf.Buckets)
+	c.buckets = make([]bucket[K, T], 0, c.conf.Buckets)
 	for i := uint(0); i < c.conf.Buckets; i++ {
-		c.buckets = append(c.buckets, bucket[T]{
+		c.buckets = append(c.buckets, bucket[K, T]{
 			conf: c.conf,
 			id:   strconv.Itoa(int(i)),
 			idx:  make(map[uint64]uint, bsize),
-			buf:  make([]entry[T], 0, bsize),
+			buf:  make([]entry[K, T], 0, bsize),
 			size: bsize,
+			evq:  c.evq,
 		})
+		if c.conf.EvictionPolicy != nil && bsize > 0 {
+			c.buckets[i].pol = c.conf.EvictionPolicy.Instance(bsize)
+		}
 	}
 
-	if c.conf.TTLInterval > 0 {
-		if c.conf.TTLInterval < time.Second {
-			return ErrShortTTL
-		}
-		if c.conf.EvictInterval == 0 {
-			c.conf.EvictInterval = c.conf.TTLInterval / 2
-		}
+	// Eviction may be required even without TTL interval, since entries may have own TTL (see SetWithTTL).
+	if c.conf.EvictInterval > 0 {
 		if c.conf.EvictWorkers == 0 {
 			c.conf.EvictWorkers = defaultEvictWorkers
 		}
@@ -289,12 +563,15 @@ func (c *cache[T]) init() error {
 			c.conf.DumpReadBuffer = c.conf.DumpReadWorkers
 		}
 		fn := func() {
-			lc, err := c.load()
+			r, err := c.load()
 			if c.l() != nil {
 				if err != nil {
-					c.l().Printf("dump read failed with error %s\n", err.Error())
-				} else {
-					c.l().Printf("read %d entries from dump\n", lc)
+					c.l().Printf("dump read interrupted due to error %s\n", err.Error())
+				}
+				c.l().Printf("read %d entries from dump: %d loaded, %d expired skipped, %d failed, %d corrupted\n",
+					r.read, r.loaded, r.skipped, r.failed, r.corrupted)
+				if r.ferr != nil {
+					c.l().Printf("dump entry load failed with error %s\n", r.ferr.Error())
 				}
 			}
 		}
@@ -308,7 +585,7 @@ func (c *cache[T]) init() error {
 	return nil
 }
 
-func (c *cache[T]) ensureValue(t T) T {
+func (c *cache[K, T]) ensureValue(t T) T {
 	typ := reflect.TypeOf(t)
 	if typ != nil && typ.Kind() == reflect.Ptr {
 		newPtr := reflect.New(typ.Elem())
@@ -1,10 +1,16 @@
 package ttlcache
 
 import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/koykov/byteconv"
+	"github.com/koykov/clock"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -73,6 +79,815 @@ func TestCache(t *testing.T) {
 		_, err = cache.Get("foo")
 		assert.ErrorIs(t, err, ErrCacheClosed)
 	})
+	t.Run("ttl", func(t *testing.T) {
+		clk := clock.NewClock()
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			Clock:       clk,
+		})
+		assert.NoError(t, err)
+		err = cache.Set("foo", testEntry{p: []byte("foobar")})
+		assert.NoError(t, err)
+		err = cache.SetWithTTL("bar", testEntry{p: []byte("qwe")}, 30*time.Second)
+		assert.NoError(t, err)
+		err = cache.SetExpireAt("baz", testEntry{p: []byte("asd")}, clk.Now().Add(time.Hour))
+		assert.NoError(t, err)
+
+		clk.Jump(45 * time.Second)
+		_, err = cache.Get("foo")
+		assert.NoError(t, err)
+		_, err = cache.Get("bar")
+		assert.True(t, err == ErrExpire || err == ErrNotFound)
+
+		clk.Jump(30 * time.Second)
+		_, err = cache.Get("foo")
+		assert.True(t, err == ErrExpire || err == ErrNotFound)
+		x, err := cache.Get("baz")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("asd"), x.p)
+
+		// Zero time means default TTL, past time means expired entry.
+		assert.NoError(t, cache.SetExpireAt("qux", testEntry{}, time.Time{}))
+		info, err := cache.GetEntry("qux")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Minute, info.TTL)
+		assert.NoError(t, cache.SetExpireAt("qux", testEntry{}, time.Unix(-1, 0)))
+		_, err = cache.Get("qux")
+		assert.ErrorIs(t, err, ErrExpire)
+		assert.NoError(t, cache.SetExpireAt("qux", testEntry{}, clk.Now().Add(-time.Second)))
+		_, err = cache.Get("qux")
+		assert.ErrorIs(t, err, ErrExpire)
+
+		err = cache.Close()
+		assert.NoError(t, err)
+	})
+	t.Run("get or load", func(t *testing.T) {
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+		})
+		assert.NoError(t, err)
+
+		var calls int32
+		loader := func(_ context.Context) (testEntry, time.Duration, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+			return testEntry{p: []byte("foobar")}, 0, nil
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				x, err := cache.GetOrLoad(context.Background(), "foo", loader)
+				assert.NoError(t, err)
+				assert.Equal(t, []byte("foobar"), x.p)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+		x, err := cache.Get("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("foobar"), x.p)
+
+		errLoad := errors.New("load failed")
+		_, err = cache.GetOrLoad(context.Background(), "bar", func(_ context.Context) (testEntry, time.Duration, error) {
+			return testEntry{}, 0, errLoad
+		})
+		assert.ErrorIs(t, err, errLoad)
+		_, err = cache.Get("bar")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = cache.GetOrLoad(context.Background(), "bar", func(_ context.Context) (testEntry, time.Duration, error) {
+			panic("boom")
+		})
+		var perr *LoadPanicError
+		assert.ErrorAs(t, err, &perr)
+		assert.Equal(t, "boom", perr.Value)
+		x, err = cache.GetOrLoad(context.Background(), "bar", loader)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("foobar"), x.p)
+
+		err = cache.Close()
+		assert.NoError(t, err)
+	})
+	t.Run("close during load", func(t *testing.T) {
+		clk := clock.NewClock()
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			StaleTTL:    time.Hour,
+			Loader: func(_ context.Context, _ string) (testEntry, time.Duration, error) {
+				time.Sleep(50 * time.Millisecond)
+				return testEntry{p: []byte("foobar")}, 0, nil
+			},
+			Clock: clk,
+		})
+		assert.NoError(t, err)
+
+		// Background refresh of stale entry.
+		assert.NoError(t, cache.Set("bar", testEntry{}))
+		clk.Jump(2 * time.Minute)
+		_, stale, err := cache.GetStale("bar")
+		assert.NoError(t, err)
+		assert.True(t, stale)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			x, err := cache.GetOrLoad(context.Background(), "foo", func(ctx context.Context) (testEntry, time.Duration, error) {
+				time.Sleep(50 * time.Millisecond)
+				return testEntry{p: []byte("foobar")}, 0, nil
+			})
+			// Loaded value returns even if the cache closed meanwhile.
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("foobar"), x.p)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, cache.Close())
+		<-done
+		// Let background refresh finish.
+		time.Sleep(100 * time.Millisecond)
+	})
+	t.Run("collision", func(t *testing.T) {
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testCollideHasher{},
+			TTLInterval: time.Minute,
+			StoreKeys:   true,
+		})
+		assert.NoError(t, err)
+		err = cache.Set("foo", testEntry{p: []byte("foobar")})
+		assert.NoError(t, err)
+		_, err = cache.Get("bar")
+		assert.ErrorIs(t, err, ErrCollision)
+		_, err = cache.Extract("bar")
+		assert.ErrorIs(t, err, ErrCollision)
+		err = cache.Delete("bar")
+		assert.ErrorIs(t, err, ErrCollision)
+		x, err := cache.Get("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("foobar"), x.p)
+		err = cache.Close()
+		assert.NoError(t, err)
+
+		// Concurrent loads of colliding keys must not share the result.
+		cache, _ = New[testEntry](&Config[testEntry]{
+			Buckets:   4,
+			Hasher:    testCollideHasher{},
+			StoreKeys: true,
+		})
+		var wg sync.WaitGroup
+		for _, key := range []string{"foo", "bar"} {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				x, err := cache.GetOrLoad(context.Background(), key, func(_ context.Context) (testEntry, time.Duration, error) {
+					time.Sleep(20 * time.Millisecond)
+					return testEntry{p: []byte(key)}, 0, nil
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, []byte(key), x.p)
+			}(key)
+		}
+		wg.Wait()
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("on evict", func(t *testing.T) {
+		var (
+			mux     sync.Mutex
+			reasons = make(map[string]EvictReason)
+		)
+		clk := clock.NewClock()
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			Clock:       clk,
+			OnEvict: func(_ uint64, value testEntry, reason EvictReason) {
+				mux.Lock()
+				reasons[string(value.p)] = reason
+				mux.Unlock()
+			},
+		})
+		assert.NoError(t, err)
+		for _, k := range []string{"foo", "bar", "baz"} {
+			assert.NoError(t, cache.Set(k, testEntry{p: []byte(k)}))
+		}
+		assert.NoError(t, cache.Set("qux", testEntry{p: []byte("old")}))
+		assert.NoError(t, cache.SetWithTTL("qux", testEntry{p: []byte("qux")}, 10*time.Second))
+		assert.NoError(t, cache.Delete("foo"))
+		_, err = cache.Extract("bar")
+		assert.NoError(t, err)
+		clk.Jump(40 * time.Second)
+		assert.NoError(t, cache.Reset())
+		assert.NoError(t, cache.Set("quux", testEntry{p: []byte("quux")}))
+		assert.NoError(t, cache.Close())
+
+		assert.Equal(t, map[string]EvictReason{
+			"foo":  EvictReasonDeleted,
+			"bar":  EvictReasonExtracted,
+			"baz":  EvictReasonReset,
+			"qux":  EvictReasonExpired,
+			"quux": EvictReasonClosed,
+			"old":  EvictReasonReplaced,
+		}, reasons)
+
+		// Update keeps the entry, thus it isn't replaced. Negative entries produce no events.
+		var replaced, empty atomic.Int32
+		cache, _ = New[testEntry](&Config[testEntry]{
+			Buckets: 1,
+			Hasher:  testHasher{},
+			OnEvict: func(_ uint64, value testEntry, reason EvictReason) {
+				if reason == EvictReasonReplaced {
+					replaced.Add(1)
+				}
+				if value.p == nil {
+					empty.Add(1)
+				}
+			},
+		})
+		assert.NoError(t, cache.SetMissing("bar", time.Minute))
+		assert.NoError(t, cache.Delete("bar"))
+		assert.NoError(t, cache.SetMissing("bar", time.Minute))
+		assert.NoError(t, cache.Set("bar", testEntry{p: []byte("bar")}))
+		assert.NoError(t, cache.SetMissing("baz", time.Minute))
+		assert.NoError(t, cache.Set("foo", testEntry{p: []byte("foo")}))
+		assert.NoError(t, cache.Update("foo", func(old testEntry, _ bool) (testEntry, bool) {
+			old.p = append(old.p, '!')
+			return old, true
+		}))
+		assert.NoError(t, cache.Set("foo", testEntry{p: []byte("bar")}))
+		assert.NoError(t, cache.Close())
+		assert.Equal(t, int32(1), replaced.Load())
+		assert.Equal(t, int32(0), empty.Load())
+
+		// Slow callback must not stall the cache, even if it uses the cache.
+		release := make(chan struct{})
+		cache, _ = New[testEntry](&Config[testEntry]{
+			Buckets:          1,
+			Hasher:           testHasher{},
+			OnEvictQueueSize: 1,
+			OnEvict: func(_ uint64, value testEntry, _ EvictReason) {
+				<-release
+				_ = cache.Delete(string(value.p))
+			},
+		})
+		for i := 0; i < 10; i++ {
+			k := strconv.Itoa(i)
+			assert.NoError(t, cache.Set(k, testEntry{p: []byte(k)}))
+			assert.NoError(t, cache.Delete(k))
+		}
+		assert.NoError(t, cache.Reset())
+		assert.Greater(t, cache.Stats().EvictDrops, uint64(0))
+		close(release)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("range", func(t *testing.T) {
+		clk := clock.NewClock()
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			Clock:       clk,
+		})
+		assert.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, cache.Set(strconv.Itoa(i), testEntry{p: getEntryBody(i)}))
+		}
+		assert.NoError(t, cache.SetWithTTL("foo", testEntry{p: []byte("foo")}, 10*time.Second))
+		clk.Jump(20 * time.Second)
+
+		var c int
+		err = cache.Range(func(key uint64, value testEntry, expiresAt time.Time) bool {
+			assert.NotEqual(t, []byte("foo"), value.p)
+			assert.True(t, expiresAt.After(clk.Now()))
+			c++
+			return true
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 100, c)
+
+		c = 0
+		for key := range cache.All() {
+			assert.NotEqual(t, testHasher{}.Sum64("foo"), key)
+			if c++; c == 10 {
+				break
+			}
+		}
+		assert.Equal(t, 10, c)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("stats", func(t *testing.T) {
+		cache, err := New[testEntry](&Config[testEntry]{
+			Size:        8,
+			Buckets:     2,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+		})
+		assert.NoError(t, err)
+		for i := 0; i < 4; i++ {
+			assert.NoError(t, cache.Set(strconv.Itoa(i), testEntry{p: getEntryBody(i)}))
+		}
+		assert.NoError(t, cache.Set("0", testEntry{p: getEntryBody(0)}))
+		_, _ = cache.Get("0")
+		_, _ = cache.Get("foo")
+		assert.Equal(t, 4, cache.Len())
+		lens := cache.BucketLens()
+		assert.Len(t, lens, 2)
+		assert.Equal(t, 4, lens[0]+lens[1])
+
+		st := cache.Stats()
+		assert.Equal(t, uint64(4), st.Len)
+		assert.Equal(t, 0.5, st.FillRatio)
+		assert.Equal(t, uint64(1), st.Hits)
+		assert.Equal(t, uint64(1), st.Misses)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("batch", func(t *testing.T) {
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+		})
+		assert.NoError(t, err)
+		src := make(map[string]testEntry)
+		keys := make([]string, 0, 50)
+		for i := 0; i < 50; i++ {
+			k := strconv.Itoa(i)
+			src[k] = testEntry{p: getEntryBody(i)}
+			keys = append(keys, k)
+		}
+		assert.NoError(t, cache.SetMany(src))
+		assert.Equal(t, 50, cache.Len())
+
+		dst := make(map[string]testEntry)
+		assert.NoError(t, cache.GetMany(append(keys, "foo"), dst))
+		assert.Equal(t, src, dst)
+
+		assert.NoError(t, cache.DeleteMany(keys[:25]))
+		clear(dst)
+		assert.NoError(t, cache.GetMany(keys, dst))
+		assert.Len(t, dst, 25)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("sliding", func(t *testing.T) {
+		clk := clock.NewClock()
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			Sliding:     true,
+			Clock:       clk,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, cache.Set("foo", testEntry{p: []byte("foo")}))
+		assert.NoError(t, cache.Set("bar", testEntry{p: []byte("bar")}))
+		assert.NoError(t, cache.Set("baz", testEntry{p: []byte("baz")}))
+		for i := 0; i < 3; i++ {
+			clk.Jump(40 * time.Second)
+			_, err = cache.Get("foo")
+			assert.NoError(t, err)
+			assert.NoError(t, cache.Touch("bar"))
+		}
+		_, err = cache.Get("baz")
+		assert.Error(t, err)
+
+		assert.NoError(t, cache.TouchWithTTL("foo", 5*time.Minute))
+		clk.Jump(4 * time.Minute)
+		_, err = cache.Get("foo")
+		assert.NoError(t, err)
+		_, err = cache.Get("bar")
+		assert.Error(t, err)
+		assert.ErrorIs(t, cache.Touch("qux"), ErrNotFound)
+
+		// Concurrent reads move deadlines under read lock.
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					_, _ = cache.Get("foo")
+					_, _ = cache.GetEntry("foo")
+				}
+			}()
+		}
+		for j := 0; j < 100; j++ {
+			clk.Jump(time.Second)
+			_ = cache.Range(func(_ uint64, _ testEntry, _ time.Time) bool { return true })
+		}
+		wg.Wait()
+		_, err = cache.Get("foo")
+		assert.NoError(t, err)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("get entry", func(t *testing.T) {
+		clk := clock.NewClock()
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			Clock:       clk,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, cache.Set("foo", testEntry{p: []byte("foo")}))
+		clk.Jump(20 * time.Second)
+		info, err := cache.GetEntry("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("foo"), info.Value.p)
+		assert.InDelta(t, float64(40*time.Second), float64(info.TTL), float64(100*time.Millisecond))
+		assert.Equal(t, time.Minute, info.ExpiresAt.Sub(info.Inserted))
+		_, err = cache.GetEntry("bar")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("stale", func(t *testing.T) {
+		clk := clock.NewClock()
+		refreshed := make(chan struct{})
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			StaleTTL:    30 * time.Second,
+			Loader: func(_ context.Context, key string) (testEntry, time.Duration, error) {
+				defer close(refreshed)
+				return testEntry{p: []byte(key + "-new")}, 0, nil
+			},
+			Clock: clk,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, cache.Set("foo", testEntry{p: []byte("foo")}))
+		assert.NoError(t, cache.Set("bar", testEntry{p: []byte("bar")}))
+		clk.Jump(70 * time.Second)
+
+		_, err = cache.Get("foo")
+		assert.ErrorIs(t, err, ErrExpire)
+		x, stale, err := cache.GetStale("foo")
+		assert.NoError(t, err)
+		assert.True(t, stale)
+		assert.Equal(t, []byte("foo"), x.p)
+		<-refreshed
+		assert.Eventually(t, func() bool {
+			x, err = cache.Get("foo")
+			return err == nil && string(x.p) == "foo-new"
+		}, time.Second, time.Millisecond)
+
+		x, err = cache.GetOrLoad(context.Background(), "bar", func(_ context.Context) (testEntry, time.Duration, error) {
+			return testEntry{}, 0, errors.New("upstream is down")
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("bar"), x.p)
+
+		clk.Jump(30 * time.Second)
+		_, _, err = cache.GetStale("bar")
+		assert.Error(t, err)
+		assert.NoError(t, cache.Close())
+
+		// Failing loader isn't called on every stale read.
+		var calls atomic.Int32
+		clk = clock.NewClock()
+		cache, _ = New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			StaleTTL:    time.Hour,
+			Loader: func(_ context.Context, _ string) (testEntry, time.Duration, error) {
+				calls.Add(1)
+				return testEntry{}, 0, errors.New("upstream is down")
+			},
+			RefreshBackoff: 10 * time.Second,
+			Clock:          clk,
+		})
+		assert.NoError(t, cache.Set("foo", testEntry{p: []byte("foo")}))
+		clk.Jump(2 * time.Minute)
+		for i := 0; i < 200; i++ {
+			_, stale, err := cache.GetStale("foo")
+			assert.NoError(t, err)
+			assert.True(t, stale)
+		}
+		assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, int32(1), calls.Load())
+		// Next refresh is allowed after backoff.
+		assert.Eventually(t, func() bool {
+			clk.Jump(10 * time.Second)
+			_, _, _ = cache.GetStale("foo")
+			return calls.Load() == 2
+		}, time.Second, 10*time.Millisecond)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("early refresh", func(t *testing.T) {
+		clk := clock.NewClock()
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:      4,
+			Hasher:       testHasher{},
+			TTLInterval:  time.Minute,
+			EarlyRefresh: 1,
+			Clock:        clk,
+		})
+		assert.NoError(t, err)
+		var calls int32
+		loader := func(_ context.Context) (testEntry, time.Duration, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(20 * time.Millisecond)
+			return testEntry{p: []byte("foo")}, 0, nil
+		}
+		_, err = cache.GetOrLoad(context.Background(), "foo", loader)
+		assert.NoError(t, err)
+
+		var refresh int
+		for i := 0; i < 100; i++ {
+			info, err := cache.GetEntry("foo")
+			assert.NoError(t, err)
+			if info.Refresh {
+				refresh++
+			}
+		}
+		assert.Equal(t, 0, refresh)
+
+		clk.Jump(time.Minute - 50*time.Millisecond)
+		for i := 0; i < 100 && atomic.LoadInt32(&calls) == 1; i++ {
+			_, err = cache.GetOrLoad(context.Background(), "foo", loader)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("missing", func(t *testing.T) {
+		clk := clock.NewClock()
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			Clock:       clk,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, cache.SetMissing("foo", 10*time.Second))
+		_, err = cache.Get("foo")
+		assert.ErrorIs(t, err, ErrCachedMiss)
+		_, err = cache.GetOrLoad(context.Background(), "foo", func(_ context.Context) (testEntry, time.Duration, error) {
+			t.Error("loader must not be called")
+			return testEntry{}, 0, nil
+		})
+		assert.ErrorIs(t, err, ErrCachedMiss)
+		assert.Equal(t, uint64(2), cache.Stats().CachedMisses)
+
+		clk.Jump(20 * time.Second)
+		_, err = cache.Get("foo")
+		assert.True(t, err == ErrExpire || err == ErrNotFound)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("tags", func(t *testing.T) {
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			StoreKeys:   true,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, cache.SetWithTags("user:1", testEntry{}, "users", "group:a"))
+		assert.NoError(t, cache.SetWithTags("user:2", testEntry{}, "users"))
+		assert.NoError(t, cache.SetWithTags("post:1", testEntry{}, "posts", "group:a"))
+		assert.NoError(t, cache.Set("post:2", testEntry{}))
+
+		n, err := cache.InvalidateTag("group:a")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		_, err = cache.Get("user:1")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = cache.Get("user:2")
+		assert.NoError(t, err)
+
+		// Overwrite drops tags.
+		assert.NoError(t, cache.Set("user:2", testEntry{}))
+		n, _ = cache.InvalidateTag("users")
+		assert.Equal(t, 0, n)
+
+		n, err = cache.DeletePrefix("post:")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, 1, cache.Len())
+		assert.NoError(t, cache.Close())
+
+		cache, _ = New[testEntry](&Config[testEntry]{Buckets: 4, Hasher: testHasher{}})
+		_, err = cache.DeletePrefix("post:")
+		assert.ErrorIs(t, err, ErrNoKeys)
+		assert.NoError(t, cache.Close())
+
+		// Refresh keeps tags.
+		clk := clock.NewClock()
+		refreshed := make(chan struct{})
+		cache, _ = New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			StaleTTL:    time.Hour,
+			Loader: func(_ context.Context, key string) (testEntry, time.Duration, error) {
+				defer close(refreshed)
+				return testEntry{p: []byte(key + "-new")}, 0, nil
+			},
+			Clock: clk,
+		})
+		assert.NoError(t, cache.SetWithTags("user:1", testEntry{p: []byte("user:1")}, "users"))
+		clk.Jump(2 * time.Minute)
+		_, stale, err := cache.GetStale("user:1")
+		assert.NoError(t, err)
+		assert.True(t, stale)
+		<-refreshed
+		assert.Eventually(t, func() bool {
+			x, err := cache.Get("user:1")
+			return err == nil && string(x.p) == "user:1-new"
+		}, time.Second, time.Millisecond)
+		n, _ = cache.InvalidateTag("users")
+		assert.Equal(t, 1, n)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("ctx", func(t *testing.T) {
+		mw := &testCtxMW{}
+		c, err := New[testEntry](&Config[testEntry]{
+			Buckets:       1,
+			Hasher:        testHasher{},
+			TTLInterval:   time.Minute,
+			MetricsWriter: mw,
+		})
+		assert.NoError(t, err)
+		cache := c.(*cache[string, testEntry])
+		ctx := context.WithValue(context.Background(), testTraceKey{}, "trace-1")
+		assert.NoError(t, cache.SetCtx(ctx, "foo", testEntry{}))
+		_, err = cache.GetCtx(ctx, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"trace-1", "trace-1"}, mw.traces)
+
+		// Emulate long operation holding the bucket.
+		b := &cache.buckets[0]
+		b.svcLock()
+		tctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = cache.GetCtx(tctx, "foo")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, cache.SetCtx(tctx, "bar", testEntry{}), context.DeadlineExceeded)
+		_, err = cache.ExtractCtx(tctx, "foo")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		b.svcUnlock()
+
+		_, err = cache.ExtractCtx(context.Background(), "foo")
+		assert.NoError(t, err)
+
+		// Writers must not starve under permanent reader load.
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						_, _ = cache.Get("foo")
+					}
+				}
+			}()
+		}
+		for i := 0; i < 100; i++ {
+			tctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			assert.NoError(t, cache.SetCtx(tctx, "foo", testEntry{}))
+			cancel()
+		}
+		close(stop)
+		wg.Wait()
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("dump expire", func(t *testing.T) {
+		clk := clock.NewClock()
+		d := &testDump{}
+		c, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			DumpWriter:  d,
+			DumpEncoder: testEndec{},
+			Clock:       clk,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, c.SetWithTTL("foo", testEntry{p: []byte("bar")}, 10*time.Minute))
+		info, _ := c.GetEntry("foo")
+		assert.NoError(t, c.(*cache[string, testEntry]).dump())
+		assert.NoError(t, c.Close())
+
+		c, err = New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			DumpReader:  d,
+			DumpDecoder: testEndec{},
+			Clock:       clock.NewClock(),
+		})
+		assert.NoError(t, err)
+		info1, err := c.GetEntry("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("bar"), info1.Value.p)
+		assert.Equal(t, info.ExpiresAt, info1.ExpiresAt)
+		assert.NoError(t, c.Close())
+	})
+	t.Run("load", func(t *testing.T) {
+		clk := clock.NewClock()
+		clk.Start()
+		now := clk.Now()
+		d := &testDump{buf: []Entry{
+			{Key: 1, Body: []byte("foo"), Expire: now.Add(time.Hour).UnixNano()},
+			{Key: 2, Body: []byte("bar"), Expire: now.Add(-time.Hour).UnixNano()},
+			{Key: 3, Body: nil, Expire: now.Add(time.Hour).UnixNano()},
+			{Key: 4, Body: []byte("qux")},
+		}}
+		mw := &testLoadMW{}
+		c, err := New[testEntry](&Config[testEntry]{
+			Buckets:       4,
+			Hasher:        testHasher{},
+			TTLInterval:   time.Minute,
+			DumpReader:    d,
+			DumpDecoder:   testEndec{},
+			MetricsWriter: mw,
+			Clock:         clk,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), mw.load.Load())
+		assert.Equal(t, int32(1), mw.skip.Load())
+		assert.Equal(t, int32(1), mw.fail.Load())
+		assert.Equal(t, 2, c.Len())
+		var exp []time.Time
+		_ = c.Range(func(_ uint64, _ testEntry, expiresAt time.Time) bool {
+			exp = append(exp, expiresAt)
+			return true
+		})
+		for _, e := range exp {
+			// Own deadline is restored, default TTL applies to entry without it.
+			assert.True(t, e.Equal(time.Unix(0, d.buf[0].Expire)) || e.Sub(now) <= time.Minute)
+		}
+		assert.NoError(t, c.Close())
+
+		// Entries without original keys can't be restored.
+		_, err = New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			StoreKeys:   true,
+			DumpReader:  d,
+			DumpDecoder: testEndec{},
+			Clock:       clk,
+		})
+		assert.ErrorIs(t, err, ErrDumpKeys)
+		_, err = New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+			StoreKeys:   true,
+			DumpWriter:  d,
+			DumpEncoder: testEndec{},
+			Clock:       clk,
+		})
+		assert.ErrorIs(t, err, ErrDumpKeys)
+	})
+	t.Run("generic key", func(t *testing.T) {
+		cache, err := NewK[int64, testEntry](&KConfig[int64, testEntry]{
+			Buckets:     4,
+			Hasher:      IntHasher[int64]{},
+			TTLInterval: time.Minute,
+			StoreKeys:   true,
+		})
+		assert.NoError(t, err)
+		for i := int64(0); i < 100; i++ {
+			assert.NoError(t, cache.Set(i, testEntry{p: getEntryBody(int(i))}))
+		}
+		for _, n := range cache.BucketLens() {
+			assert.Greater(t, n, 0)
+		}
+		v, err := cache.Get(42)
+		assert.NoError(t, err)
+		assert.Equal(t, getEntryBody(42), v.p)
+		_, err = cache.DeletePrefix("4")
+		assert.ErrorIs(t, err, ErrKeyKind)
+		assert.NoError(t, cache.Close())
+
+		ucache, err := NewK[userID, testEntry](&KConfig[userID, testEntry]{
+			Buckets:   4,
+			Hasher:    userIDHasher{},
+			StoreKeys: true,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, ucache.Set("user:1", testEntry{}))
+		assert.NoError(t, ucache.Set("user:2", testEntry{}))
+		n, err := ucache.DeletePrefix("user:")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.NoError(t, ucache.Close())
+	})
 }
 
 func TestIO(t *testing.T) {
@@ -0,0 +1,82 @@
+package ttlcache
+
+const (
+	cmsDepth = 4
+	cmsMax   = 15
+
+	cmsWidthFactor = 8
+)
+
+var cmsSeeds = [cmsDepth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+// Count-min sketch to estimate access frequency of keys.
+//
+// Counters saturate at 15 and halve periodically (aging), so old popularity fades over time.
+type cmSketch struct {
+	rows  [cmsDepth][]uint8
+	mask  uint64
+	adds  uint64
+	limit uint64
+}
+
+func newCMSketch(size uint64) *cmSketch {
+	// Make sketch wider than bucket size to reduce counters pollution by rare keys.
+	w := uint64(16)
+	for w < size*cmsWidthFactor {
+		w <<= 1
+	}
+	if size < 16 {
+		size = 16
+	}
+	s := &cmSketch{mask: w - 1, limit: size * 10}
+	for i := 0; i < cmsDepth; i++ {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+func (s *cmSketch) add(hkey uint64) {
+	for i := 0; i < cmsDepth; i++ {
+		j := s.index(hkey, i)
+		if s.rows[i][j] < cmsMax {
+			s.rows[i][j]++
+		}
+	}
+	if s.adds++; s.adds >= s.limit {
+		s.age()
+	}
+}
+
+func (s *cmSketch) estimate(hkey uint64) uint8 {
+	est := uint8(cmsMax)
+	for i := 0; i < cmsDepth; i++ {
+		if c := s.rows[i][s.index(hkey, i)]; c < est {
+			est = c
+		}
+	}
+	return est
+}
+
+func (s *cmSketch) age() {
+	for i := 0; i < cmsDepth; i++ {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.adds /= 2
+}
+
+func (s *cmSketch) reset() {
+	for i := 0; i < cmsDepth; i++ {
+		for j := range s.rows[i] {
+			s.rows[i][j] = 0
+		}
+	}
+	s.adds = 0
+}
+
+func (s *cmSketch) index(hkey uint64, row int) uint64 {
+	h := (hkey ^ cmsSeeds[row]) * 0x9e3779b97f4a7c15
+	h ^= h >> 32
+	return h & s.mask
+}
@@ -1,6 +1,13 @@
 package ttlcache
 
-import "strconv"
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
 
 var (
 	dataPool = [][]byte{
@@ -23,3 +30,79 @@ func makeKey(dst []byte, i int) []byte {
 func getEntryBody(i int) []byte {
 	return dataPool[i%dpLen]
 }
+
+type testTraceKey struct{}
+
+// Metrics writer collecting trace IDs of context-aware calls.
+type testCtxMW struct {
+	dummyMW
+	traces []string
+}
+
+func (w *testCtxMW) WithContext(ctx context.Context) MetricsWriter {
+	trace, _ := ctx.Value(testTraceKey{}).(string)
+	return testTraceMW{parent: w, trace: trace}
+}
+
+type testTraceMW struct {
+	dummyMW
+	parent *testCtxMW
+	trace  string
+}
+
+func (w testTraceMW) Set(_ string, _ time.Duration) {
+	w.parent.traces = append(w.parent.traces, w.trace)
+}
+
+func (w testTraceMW) Hit(_ string, _ time.Duration) {
+	w.parent.traces = append(w.parent.traces, w.trace)
+}
+
+// In-memory dump writer and reader.
+type testDump struct {
+	buf []Entry
+	off int
+}
+
+func (d *testDump) Write(e Entry) (int, error) {
+	e.Body = append([]byte(nil), e.Body...)
+	d.buf = append(d.buf, e)
+	return len(e.Body), nil
+}
+
+func (d *testDump) Flush() error { return nil }
+
+func (d *testDump) Read() (Entry, error) {
+	if d.off >= len(d.buf) {
+		return Entry{}, io.EOF
+	}
+	d.off++
+	return d.buf[d.off-1], nil
+}
+
+// Raw bytes encoder/decoder of testEntry.
+type testEndec struct{}
+
+func (testEndec) Encode(dst []byte, v testEntry) ([]byte, int, error) {
+	return append(dst, v.p...), len(v.p), nil
+}
+
+func (testEndec) Decode(v *testEntry, p []byte) error {
+	if len(p) == 0 {
+		return errTestDecode
+	}
+	v.p = append(v.p[:0], p...)
+	return nil
+}
+
+var errTestDecode = errors.New("empty body")
+
+// Metrics writer counting dump load events.
+type testLoadMW struct {
+	dummyMW
+	load, skip, fail atomic.Int32
+}
+
+func (w *testLoadMW) Load(_ string)     { w.load.Add(1) }
+func (w *testLoadMW) LoadSkip(_ string) { w.skip.Add(1) }
+func (w *testLoadMW) LoadFail(_ string) { w.fail.Add(1) }
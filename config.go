@@ -2,14 +2,47 @@ package ttlcache
 
 import "time"
 
-type Config[T any] struct {
+// KConfig is a config of cache with keys of type K.
+type KConfig[K comparable, T any] struct {
 	Size          uint64
 	Buckets       uint
-	Hasher        Hasher
+	Hasher        KeyHasher[K]
 	TTLInterval   time.Duration
 	EvictInterval time.Duration
 	EvictWorkers  uint
 
+	// TTLJitter randomly shortens TTL of every entry within given range to spread out mass expirations.
+	TTLJitter time.Duration
+	// TTLJitterRatio is the same as TTLJitter, but relative to TTL (e.g. 0.1 means up to 10% of TTL).
+	TTLJitterRatio float64
+	// Sliding enables sliding expiration: every successful read moves entry's deadline forward by its TTL. Reads
+	// don't take the write lock, expiration index catches up moved deadlines on eviction.
+	Sliding bool
+	// StaleTTL is a grace period after deadline, during which expired entry still available using GetStale and
+	// GetOrLoad (when loader fails).
+	StaleTTL time.Duration
+	// Loader refreshes stale entries in background.
+	Loader KLoader[K, T]
+	// RefreshBackoff is a minimal pause between background refreshes of the same stale entry, so failing loader
+	// isn't called on every read. Defaults to one second.
+	RefreshBackoff time.Duration
+	// EarlyRefresh enables probabilistic early expiration (XFetch) of entries loaded by GetOrLoad. The value is a beta
+	// parameter of the algorithm, 1.0 is a good default; greater values favor earlier refresh.
+	EarlyRefresh float64
+
+	// EvictionPolicy applies when bucket reaches the size limit. If omitted, new entries rejects with ErrOverflow.
+	EvictionPolicy EvictionPolicy
+
+	// OnEvict calls every time when entry leaves the cache. Calls asynchronously outside the bucket lock. Negative
+	// entries (see SetMissing) have no value, thus don't produce calls.
+	OnEvict func(key uint64, value T, reason EvictReason)
+	// OnEvictQueueSize limits queue of eviction events. Events are dropped when queue is full (see Stats.EvictDrops).
+	OnEvictQueueSize uint
+
+	// StoreKeys enables storing of original keys to detect hash collisions (see ErrCollision).
+	// Dump doesn't contain original keys, thus it can't be used together (see ErrDumpKeys).
+	StoreKeys bool
+
 	DumpWriter       DumpWriter
 	DumpEncoder      Encoder[T]
 	DumpInterval     time.Duration
@@ -26,7 +59,10 @@ type Config[T any] struct {
 	Logger        Logger
 }
 
-func (c *Config[T]) Copy() *Config[T] {
+// Config is a config of cache with string keys.
+type Config[T any] = KConfig[string, T]
+
+func (c *KConfig[K, T]) Copy() *KConfig[K, T] {
 	cpy := *c
 	return &cpy
 }
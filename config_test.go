@@ -1,6 +1,7 @@
 package ttlcache
 
 import (
+	"runtime"
 	"testing"
 	"time"
 
@@ -16,4 +17,15 @@ func TestConfig(t *testing.T) {
 		conf.TTLInterval = 30 * time.Second
 		assert.Equal(t, time.Minute, cpy.TTLInterval)
 	})
+	t.Run("invalid", func(t *testing.T) {
+		n := runtime.NumGoroutine()
+		_, err := New[testEntry](&Config[testEntry]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Millisecond,
+			OnEvict:     func(_ uint64, _ testEntry, _ EvictReason) {},
+		})
+		assert.ErrorIs(t, err, ErrShortTTL)
+		assert.Equal(t, n, runtime.NumGoroutine())
+	})
 }
@@ -1,5 +1,7 @@
 package ttlcache
 
+import "time"
+
 const (
 	cacheStatusNil    = 0
 	cacheStatusActive = 1
@@ -8,4 +10,9 @@ const (
 	defaultEvictWorkers     = 16
 	defaultDumpWriteWorkers = 16
 	defaultDumpReadWorkers  = 16
+	defaultOnEvictQueueSize = 1024
+	defaultRefreshBackoff   = time.Second
+
+	// Max entries to evict per one bucket lock.
+	evictSliceSize = 1024
 )
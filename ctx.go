@@ -0,0 +1,47 @@
+package ttlcache
+
+import (
+	"context"
+	"time"
+)
+
+// SetCtx is a context-aware version of Set. Gives up waiting for the bucket lock when ctx is done and returns ctx.Err().
+func (c *cache[K, T]) SetCtx(ctx context.Context, key K, value T) error {
+	return c.set(ctx, key, value, 0)
+}
+
+// SetWithTTLCtx is a context-aware version of SetWithTTL.
+func (c *cache[K, T]) SetWithTTLCtx(ctx context.Context, key K, value T, ttl time.Duration) error {
+	return c.set(ctx, key, value, c.deadline(ttl))
+}
+
+// GetCtx is a context-aware version of Get. Gives up waiting for the bucket lock when ctx is done and returns
+// ctx.Err().
+func (c *cache[K, T]) GetCtx(ctx context.Context, key K) (T, error) {
+	if err := c.checkCache(cacheStatusActive); err != nil {
+		return c.null, err
+	}
+	hkey := c.conf.Hasher.Sum64(key)
+	b := &c.buckets[hkey%uint64(c.conf.Buckets)]
+	return b.get(ctx, hkey, key)
+}
+
+// DeleteCtx is a context-aware version of Delete.
+func (c *cache[K, T]) DeleteCtx(ctx context.Context, key K) error {
+	if err := c.checkCache(cacheStatusActive); err != nil {
+		return err
+	}
+	hkey := c.conf.Hasher.Sum64(key)
+	b := &c.buckets[hkey%uint64(c.conf.Buckets)]
+	return b.delete(ctx, hkey, key)
+}
+
+// ExtractCtx is a context-aware version of Extract.
+func (c *cache[K, T]) ExtractCtx(ctx context.Context, key K) (T, error) {
+	if err := c.checkCache(cacheStatusActive); err != nil {
+		return c.null, err
+	}
+	hkey := c.conf.Hasher.Sum64(key)
+	b := &c.buckets[hkey%uint64(c.conf.Buckets)]
+	return b.extract(ctx, hkey, key)
+}
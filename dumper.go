@@ -1,9 +1,12 @@
 package ttlcache
 
 type Entry struct {
-	Key    uint64
-	Body   []byte
-	Expire uint32
+	Key  uint64
+	Body []byte
+	// Expire is an absolute deadline of the entry (UnixNano). Zero means that entry has no own deadline and config's
+	// TTL interval applies.
+	Expire int64
+	Tags   []string
 }
 
 type DumpWriter interface {
@@ -0,0 +1,102 @@
+package dumpfs
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+)
+
+// Compressor compresses blocks of dump file. Compressor's name writes to the file header, so reader can pick the
+// same compressor. Decompress should reject output larger than 1GiB, which is a limit of the block size.
+type Compressor interface {
+	Name() string
+	Compress(dst, p []byte) ([]byte, error)
+	Decompress(dst, p []byte) ([]byte, error)
+}
+
+// Gzip compressor. Zero level means default compression.
+type Gzip struct {
+	Level int
+}
+
+func (Gzip) Name() string {
+	return "gzip"
+}
+
+func (c Gzip) Compress(dst, p []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	zw, err := gzip.NewWriterLevel(buf, level(c.Level))
+	if err != nil {
+		return dst, err
+	}
+	if _, err = zw.Write(p); err != nil {
+		return dst, err
+	}
+	if err = zw.Close(); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (Gzip) Decompress(dst, p []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(p))
+	if err != nil {
+		return dst, err
+	}
+	return readAll(dst, zr, maxBlockSize)
+}
+
+// Flate compressor. Zero level means default compression.
+type Flate struct {
+	Level int
+}
+
+func (Flate) Name() string {
+	return "flate"
+}
+
+func (c Flate) Compress(dst, p []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	zw, err := flate.NewWriter(buf, level(c.Level))
+	if err != nil {
+		return dst, err
+	}
+	if _, err = zw.Write(p); err != nil {
+		return dst, err
+	}
+	if err = zw.Close(); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (Flate) Decompress(dst, p []byte) ([]byte, error) {
+	return readAll(dst, flate.NewReader(bytes.NewReader(p)), maxBlockSize)
+}
+
+// Built-in compressors available to reader without explicit WithDecompressor.
+var compressors = map[string]Compressor{
+	Gzip{}.Name():  Gzip{},
+	Flate{}.Name(): Flate{},
+}
+
+func level(l int) int {
+	if l == 0 {
+		return flate.DefaultCompression
+	}
+	return l
+}
+
+// Read decompressed data up to limit bytes. Data over the limit means corrupted or malicious block.
+func readAll(dst []byte, r io.ReadCloser, limit int64) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	n, err := buf.ReadFrom(io.LimitReader(r, limit+1))
+	if err1 := r.Close(); err == nil {
+		err = err1
+	}
+	if err == nil && n > limit {
+		err = ErrBadBlock
+	}
+	return buf.Bytes(), err
+}
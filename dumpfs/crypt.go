@@ -0,0 +1,76 @@
+package dumpfs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+)
+
+// KeyProvider provides AES keys (16, 24 or 32 bytes) to encrypt and decrypt dumps.
+type KeyProvider interface {
+	// Current returns ID and the key to encrypt new dumps.
+	Current() (id string, key []byte, err error)
+	// Key returns the key by ID to decrypt existing dumps. Must keep old keys available for rotation.
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is a simple KeyProvider with fixed set of keys.
+type StaticKeys struct {
+	// CurrentID is an ID of the key to encrypt new dumps.
+	CurrentID string
+	Keys      map[string][]byte
+}
+
+func (k StaticKeys) Current() (string, []byte, error) {
+	key, err := k.Key(k.CurrentID)
+	return k.CurrentID, key, err
+}
+
+func (k StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, ErrNoKey
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Make additional data: file header, kind and sequence number of the block (or footer) and number of records. Thus
+// blocks can't be reordered, moved to other file or dropped unnoticed.
+func appendAD(dst, hdr []byte, kind byte, seq, records uint64) []byte {
+	dst = append(dst, hdr...)
+	dst = append(dst, kind)
+	dst = binary.LittleEndian.AppendUint64(dst, seq)
+	return binary.LittleEndian.AppendUint64(dst, records)
+}
+
+// Encrypt the payload. Nonce prepends to the result.
+func sealBlock(dst []byte, aead cipher.AEAD, ad, p []byte) ([]byte, error) {
+	off := len(dst)
+	dst = append(dst, make([]byte, aead.NonceSize())...)
+	if _, err := rand.Read(dst[off:]); err != nil {
+		return dst[:off], err
+	}
+	return aead.Seal(dst, dst[off:], p, ad), nil
+}
+
+// Decrypt the payload encrypted by sealBlock.
+func openBlock(dst []byte, aead cipher.AEAD, ad, p []byte) ([]byte, error) {
+	ns := aead.NonceSize()
+	if len(p) < ns {
+		return dst, ErrAuth
+	}
+	dst, err := aead.Open(dst, p[:ns], p[ns:], ad)
+	if err != nil {
+		return dst, ErrAuth
+	}
+	return dst, nil
+}
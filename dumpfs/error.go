@@ -5,4 +5,14 @@ import "errors"
 var (
 	ErrNoFilePath = errors.New("no filepath provided")
 	ErrDirNoWR    = errors.New("directory doesn't exists or writable")
+
+	ErrBadHeader     = errors.New("dump header is malformed")
+	ErrVersion       = errors.New("unsupported dump version")
+	ErrCodecMismatch = errors.New("dump codec mismatch")
+	ErrCompression   = errors.New("unknown dump compression")
+	ErrNoKey         = errors.New("dump encryption key not found")
+	ErrAuth          = errors.New("dump block authentication failed")
+	ErrBadBlock      = errors.New("dump block is malformed")
+	ErrBadRecord     = errors.New("dump record is malformed")
+	ErrFieldSize     = errors.New("dump tag or header field exceeds 65535 bytes")
 )
@@ -0,0 +1,191 @@
+package dumpfs
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/koykov/bytealg"
+	"github.com/koykov/ttlcache"
+)
+
+// Dump file format v3:
+//
+//	header: magic[4] version[2] created[8] fields[1] {type[1] len[2] value}... crc32c[4]
+//	block:  'B' len[4] records[4] crc32c[4] payload
+//	footer: 'F' records[8] crc32c[4] [auth]
+//
+// Payload of the block is a sequence of records:
+//
+//	key[8] len[4] body expire[8] tags[2] {len[2] tag}...
+//
+// where expire is an absolute deadline (UnixNano). If the header contains compression field, payload is compressed
+// (see Compressor). If the header contains key ID field, payload is encrypted using AES-GCM after compression:
+// nonce[12] ciphertext. Additional data is the header followed by kind[1] seq[8] records[8], where seq is a number of
+// the block in the file. Footer of encrypted file contains auth field: nonce[12] tag[16] of empty payload with the
+// same additional data, where seq is a total number of blocks. Encrypted file without valid footer is rejected.
+// Block's len and crc32c relate to stored payload. All numbers are little endian.
+//
+// Version 2 differs by 32-bit expire field. Version 1 files has no header, blocks and footer, just a sequence of
+// records key[8] len[4] body expire[4]. Legacy 32-bit expire fields contain truncated timestamps, thus they are
+// ignored on read. Version 1 can't be detected reliably, so reader accepts it only on demand (see WithLegacyFormat).
+
+const (
+	version1 = 1
+	version2 = 2
+	version3 = 3
+
+	headerSize      = 4 + 2 + 8 + 1
+	blockHeaderSize = 1 + 4 + 4 + 4
+	footerSize      = 1 + 8 + 4
+
+	blockKind  = 'B'
+	footerKind = 'F'
+
+	// Header fields types.
+	fieldCodec       = 1
+	fieldCompression = 2
+	fieldKeyID       = 3
+
+	// Sanity limit of block's payload to detect corrupted block header.
+	maxBlockSize = 1 << 30
+	// Limit of values with 16-bit length (header fields, tags).
+	maxFieldSize = 1<<16 - 1
+)
+
+var (
+	magic = [4]byte{'T', 'T', 'L', 'D'}
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// Header represents the dump file header.
+type Header struct {
+	Version uint16
+	// Created is a creation time of the file (UnixNano).
+	Created int64
+	// Codec is a name of the codec that encodes entries bodies.
+	Codec string
+	// Compression is a name of the compressor of blocks (see Compressor). Empty if blocks are not compressed.
+	Compression string
+	// KeyID is an ID of the key that encrypts blocks (see KeyProvider). Empty if blocks are not encrypted.
+	KeyID string
+}
+
+func appendHeader(dst []byte, h *Header) []byte {
+	off := len(dst)
+	dst = append(dst, magic[:]...)
+	dst = binary.LittleEndian.AppendUint16(dst, h.Version)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(h.Created))
+	noff := len(dst)
+	dst = append(dst, 0)
+	dst = appendField(dst, fieldCodec, h.Codec)
+	dst = appendField(dst, fieldCompression, h.Compression)
+	dst = appendField(dst, fieldKeyID, h.KeyID)
+	// Count non-empty fields.
+	for p := dst[noff+1:]; len(p) > 0; {
+		dst[noff]++
+		p = p[3+int(binary.LittleEndian.Uint16(p[1:])):]
+	}
+	return binary.LittleEndian.AppendUint32(dst, crc32.Checksum(dst[off:], crcTable))
+}
+
+// Check if header values fit to the format.
+func checkHeader(h *Header) error {
+	if len(h.Codec) > maxFieldSize || len(h.Compression) > maxFieldSize || len(h.KeyID) > maxFieldSize {
+		return ErrFieldSize
+	}
+	return nil
+}
+
+// Append non-empty field.
+func appendField(dst []byte, typ byte, value string) []byte {
+	if len(value) == 0 {
+		return dst
+	}
+	dst = append(dst, typ)
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(len(value)))
+	return append(dst, value...)
+}
+
+func appendBlockHeader(dst []byte, records uint32, payload []byte) []byte {
+	dst = append(dst, blockKind)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(payload)))
+	dst = binary.LittleEndian.AppendUint32(dst, records)
+	return binary.LittleEndian.AppendUint32(dst, crc32.Checksum(payload, crcTable))
+}
+
+func appendFooter(dst []byte, records uint64) []byte {
+	off := len(dst)
+	dst = append(dst, footerKind)
+	dst = binary.LittleEndian.AppendUint64(dst, records)
+	return binary.LittleEndian.AppendUint32(dst, crc32.Checksum(dst[off:], crcTable))
+}
+
+// Check if entry fits to the record format.
+func checkRecord(e *ttlcache.Entry) error {
+	if len(e.Tags) > maxFieldSize {
+		return ErrFieldSize
+	}
+	for _, tag := range e.Tags {
+		if len(tag) > maxFieldSize {
+			return ErrFieldSize
+		}
+	}
+	return nil
+}
+
+func appendRecord(dst []byte, e *ttlcache.Entry) []byte {
+	dst = binary.LittleEndian.AppendUint64(dst, e.Key)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(e.Body)))
+	dst = append(dst, e.Body...)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(e.Expire))
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(len(e.Tags)))
+	for _, tag := range e.Tags {
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(len(tag)))
+		dst = append(dst, tag...)
+	}
+	return dst
+}
+
+// Decode the record of given version from p. Returns number of consumed bytes.
+func decodeRecord(e *ttlcache.Entry, p []byte, version uint16) (int, error) {
+	off := 0
+	if len(p) < 12 {
+		return 0, ErrBadRecord
+	}
+	e.Key = binary.LittleEndian.Uint64(p[off:])
+	off += 8
+	l := int(binary.LittleEndian.Uint32(p[off:]))
+	off += 4
+	el := 8
+	if version < version3 {
+		el = 4
+	}
+	if len(p[off:]) < l+el+2 {
+		return 0, ErrBadRecord
+	}
+	e.Body = bytealg.Copy(p[off : off+l])
+	off += l
+	if el == 8 {
+		e.Expire = int64(binary.LittleEndian.Uint64(p[off:]))
+	}
+	off += el
+	n := int(binary.LittleEndian.Uint16(p[off:]))
+	off += 2
+	if n > 0 {
+		e.Tags = make([]string, 0, n)
+	}
+	for i := 0; i < n; i++ {
+		if len(p[off:]) < 2 {
+			return 0, ErrBadRecord
+		}
+		tl := int(binary.LittleEndian.Uint16(p[off:]))
+		off += 2
+		if len(p[off:]) < tl {
+			return 0, ErrBadRecord
+		}
+		e.Tags = append(e.Tags, string(p[off:off+tl]))
+		off += tl
+	}
+	return off, nil
+}
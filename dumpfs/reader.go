@@ -1,7 +1,11 @@
 package dumpfs
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
 	"encoding/binary"
+	"hash/crc32"
 	"io"
 	"os"
 	"sync"
@@ -12,15 +16,44 @@ import (
 
 type Reader interface {
 	Read() (ttlcache.Entry, error)
+	// Skipped returns number of records skipped due to corruption of the file. Records of truncated file without
+	// footer can't be counted.
+	Skipped() uint64
 }
 
 type reader struct {
-	fp  string
-	eof func(string) error
+	fp    string
+	eof   func(string) error
+	codec string
+	comps map[string]Compressor
+	kp    KeyProvider
+	// Allow legacy files without header.
+	legacy bool
 
 	mux sync.Mutex
 	f   *os.File
+	rd  *bufio.Reader
 	buf []byte
+	hdr Header
+
+	// Current block and records left in it.
+	blk  []byte
+	left uint32
+	// Raw (encrypted or compressed), decrypted and decompressed block.
+	raw, plain, dec []byte
+	comp            Compressor
+	aead            cipher.AEAD
+	// Header of the file and additional data of encrypted block.
+	hbuf, ad []byte
+	// Records read and skipped in the file.
+	nr, ns uint64
+	// Blocks read in the file.
+	seq uint64
+	// Total skipped records.
+	skipped uint64
+
+	// Error of the file that can't be read further.
+	err error
 }
 
 func NewReader(filepath string, options ...ROption) (Reader, error) {
@@ -41,54 +74,274 @@ func (r *reader) Read() (e ttlcache.Entry, err error) {
 		r.mux.Unlock()
 	}()
 
+	if r.err != nil {
+		err = r.err
+		return
+	}
 	if r.f == nil {
-		if r.f, err = os.OpenFile(r.fp, os.O_RDONLY, 0644); err != nil {
+		if err = r.open(); err != nil {
 			return
 		}
 	}
+	if r.hdr.Version == version1 {
+		return r.readV1()
+	}
+	if e, err = r.readV2(); err == ErrBadBlock || err == ErrAuth {
+		// Block framing is broken or file can't be trusted, so the rest of the file can't be read.
+		r.err = err
+		r.close()
+	}
+	return
+}
 
-	r.buf = r.buf[:0]
-	off := 0
-	r.buf = bytealg.GrowDelta(r.buf, 8)
-	if _, err = io.ReadAtLeast(r.f, r.buf[off:], 8); err != nil {
+func (r *reader) Skipped() uint64 {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.skipped
+}
+
+// Open the file and read its header.
+func (r *reader) open() (err error) {
+	if r.f, err = os.OpenFile(r.fp, os.O_RDONLY, 0644); err != nil {
 		return
 	}
-	e.Key = binary.LittleEndian.Uint64(r.buf[off:])
-	off += 8
+	r.rd = bufio.NewReader(r.f)
+	r.hdr, r.blk, r.left, r.nr, r.ns, r.seq, r.comp, r.aead = Header{}, nil, 0, 0, 0, 0, nil, nil
+	defer func() {
+		if err != nil {
+			r.close()
+		}
+	}()
+
+	var p []byte
+	if p, err = r.rd.Peek(len(magic)); err != nil || !bytes.Equal(p, magic[:]) {
+		if len(p) == 0 && err == io.EOF {
+			// Empty file.
+			return io.EOF
+		}
+		if !r.legacy {
+			return ErrBadHeader
+		}
+		// Legacy file without header.
+		r.hdr.Version = version1
+		return nil
+	}
 
-	var l int
+	r.buf = bytealg.GrowDelta(r.buf[:0], headerSize)
+	if _, err = io.ReadFull(r.rd, r.buf); err != nil {
+		return ErrBadHeader
+	}
+	r.hdr.Version = binary.LittleEndian.Uint16(r.buf[4:])
+	r.hdr.Created = int64(binary.LittleEndian.Uint64(r.buf[6:]))
+	if r.hdr.Version != version2 && r.hdr.Version != version3 {
+		return ErrVersion
+	}
+	n := int(r.buf[headerSize-1])
+	for i := 0; i < n; i++ {
+		off := len(r.buf)
+		r.buf = bytealg.GrowDelta(r.buf, 3)
+		if _, err = io.ReadFull(r.rd, r.buf[off:]); err != nil {
+			return ErrBadHeader
+		}
+		typ, l := r.buf[off], int(binary.LittleEndian.Uint16(r.buf[off+1:]))
+		r.buf = bytealg.GrowDelta(r.buf, l)
+		if _, err = io.ReadFull(r.rd, r.buf[off+3:]); err != nil {
+			return ErrBadHeader
+		}
+		// Unknown fields are skipped for forward compatibility.
+		switch typ {
+		case fieldCodec:
+			r.hdr.Codec = string(r.buf[off+3:])
+		case fieldCompression:
+			r.hdr.Compression = string(r.buf[off+3:])
+		case fieldKeyID:
+			r.hdr.KeyID = string(r.buf[off+3:])
+		}
+	}
+	off := len(r.buf)
 	r.buf = bytealg.GrowDelta(r.buf, 4)
-	if _, err = io.ReadAtLeast(r.f, r.buf[off:], 4); err != nil {
-		return
+	if _, err = io.ReadFull(r.rd, r.buf[off:]); err != nil {
+		return ErrBadHeader
 	}
-	l = int(binary.LittleEndian.Uint32(r.buf[off:]))
-	off += 4
-	blo := off
-	r.buf = bytealg.GrowDelta(r.buf, l)
-	if _, err = io.ReadAtLeast(r.f, r.buf[off:], l); err != nil {
-		return
+	if crc32.Checksum(r.buf[:off], crcTable) != binary.LittleEndian.Uint32(r.buf[off:]) {
+		return ErrBadHeader
+	}
+	if len(r.codec) > 0 && r.codec != r.hdr.Codec {
+		return ErrCodecMismatch
+	}
+	if c := r.hdr.Compression; len(c) > 0 {
+		if r.comp = r.comps[c]; r.comp == nil {
+			if r.comp = compressors[c]; r.comp == nil {
+				return ErrCompression
+			}
+		}
 	}
-	off += l
-	bhi := off
+	if id := r.hdr.KeyID; len(id) > 0 {
+		if r.kp == nil {
+			return ErrNoKey
+		}
+		var key []byte
+		if key, err = r.kp.Key(id); err != nil {
+			return
+		}
+		if r.aead, err = newAEAD(key); err != nil {
+			return
+		}
+		r.hbuf = append(r.hbuf[:0], r.buf...)
+	}
+	return nil
+}
 
-	r.buf = bytealg.GrowDelta(r.buf, 4)
-	if _, err = io.ReadAtLeast(r.f, r.buf[off:], 4); err != nil {
-		return
+func (r *reader) readV2() (e ttlcache.Entry, err error) {
+	for {
+		if r.left > 0 {
+			r.left--
+			var n int
+			if n, err = decodeRecord(&e, r.blk, r.hdr.Version); err != nil {
+				// Checksum matched, but block is malformed. Skip the rest of it.
+				r.skip(uint64(r.left) + 1)
+				r.left = 0
+				continue
+			}
+			r.blk = r.blk[n:]
+			r.nr++
+			return
+		}
+		if err = r.nextBlock(); err != nil {
+			return
+		}
 	}
-	e.Expire = binary.LittleEndian.Uint32(r.buf[off:])
-	off += 4
+}
 
-	e.Body = append(e.Body, r.buf[blo:bhi]...)
+// Read next block. Corrupted blocks are skipped.
+func (r *reader) nextBlock() (err error) {
+	for {
+		r.buf = bytealg.GrowDelta(r.buf[:0], 1)
+		if _, err = io.ReadFull(r.rd, r.buf); err != nil {
+			// No footer, file is truncated.
+			return r.truncated()
+		}
+		switch r.buf[0] {
+		case footerKind:
+			r.buf = bytealg.GrowDelta(r.buf, footerSize-1)
+			if _, err = io.ReadFull(r.rd, r.buf[1:]); err != nil {
+				return r.truncated()
+			}
+			if crc32.Checksum(r.buf[:9], crcTable) != binary.LittleEndian.Uint32(r.buf[9:]) {
+				return r.truncated()
+			}
+			total := binary.LittleEndian.Uint64(r.buf[1:])
+			if r.aead != nil {
+				off := len(r.buf)
+				r.buf = bytealg.GrowDelta(r.buf, r.aead.NonceSize()+r.aead.Overhead())
+				if _, err = io.ReadFull(r.rd, r.buf[off:]); err != nil {
+					return r.truncated()
+				}
+				r.ad = appendAD(r.ad[:0], r.hbuf, footerKind, r.seq, total)
+				if r.plain, err = openBlock(r.plain[:0], r.aead, r.ad, r.buf[off:]); err != nil {
+					return
+				}
+			}
+			if total > r.nr+r.ns {
+				// Records lost in blocks with corrupted headers.
+				r.skip(total - r.nr - r.ns)
+			}
+			return io.EOF
+		case blockKind:
+			r.buf = bytealg.GrowDelta(r.buf, blockHeaderSize-1)
+			if _, err = io.ReadFull(r.rd, r.buf[1:]); err != nil {
+				return r.truncated()
+			}
+			l := binary.LittleEndian.Uint32(r.buf[1:])
+			records := binary.LittleEndian.Uint32(r.buf[5:])
+			crc := binary.LittleEndian.Uint32(r.buf[9:])
+			if l > maxBlockSize {
+				return ErrBadBlock
+			}
+			seq := r.seq
+			r.seq++
+			r.raw = bytealg.GrowDelta(r.raw[:0], int(l))
+			if _, err = io.ReadFull(r.rd, r.raw); err != nil {
+				r.skip(uint64(records))
+				return r.truncated()
+			}
+			if crc32.Checksum(r.raw, crcTable) != crc {
+				r.skip(uint64(records))
+				continue
+			}
+			r.blk = r.raw
+			if r.aead != nil {
+				// Checksum matched, thus authentication failure means wrong key or tampered file.
+				r.ad = appendAD(r.ad[:0], r.hbuf, blockKind, seq, uint64(records))
+				if r.plain, err = openBlock(r.plain[:0], r.aead, r.ad, r.blk); err != nil {
+					return
+				}
+				r.blk = r.plain
+			}
+			if r.comp != nil {
+				if r.dec, err = r.comp.Decompress(r.dec[:0], r.blk); err != nil || len(r.dec) > maxBlockSize {
+					r.skip(uint64(records))
+					continue
+				}
+				r.blk = r.dec
+			}
+			r.left = records
+			return nil
+		default:
+			return ErrBadBlock
+		}
+	}
+}
 
+// End of file without verified footer. Encrypted file can't be trusted in that case, since blocks may be cut off.
+func (r *reader) truncated() error {
+	if r.aead != nil {
+		return ErrAuth
+	}
+	return io.EOF
+}
+
+func (r *reader) skip(n uint64) {
+	r.ns += n
+	r.skipped += n
+}
+
+func (r *reader) readV1() (e ttlcache.Entry, err error) {
+	r.buf = bytealg.GrowDelta(r.buf[:0], 12)
+	if _, err = io.ReadFull(r.rd, r.buf); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return
+	}
+	e.Key = binary.LittleEndian.Uint64(r.buf)
+	l := int(binary.LittleEndian.Uint32(r.buf[8:]))
+
+	r.buf = bytealg.GrowDelta(r.buf[:0], l+4)
+	if _, err = io.ReadFull(r.rd, r.buf); err != nil {
+		// Truncated record.
+		r.skip(1)
+		err = io.EOF
+		return
+	}
+	// Legacy expire field is ignored, see format description.
+	e.Body = append(e.Body, r.buf[:l]...)
+	r.nr++
 	return
 }
 
 func (r *reader) checkEOF(err error) error {
 	if err == io.EOF {
-		_ = r.f.Close()
+		r.close()
 		_ = r.eof(r.fp)
 		r.fp = ""
-		r.f = nil
 	}
 	return err
 }
+
+func (r *reader) close() {
+	if r.f != nil {
+		_ = r.f.Close()
+	}
+	r.f, r.rd = nil, nil
+}
@@ -7,3 +7,36 @@ func WithOnEOF(onEOF OnEOF) ROption {
 		r.eof = onEOF
 	}
 }
+
+// WithExpectCodec rejects dump files written with codec other than name (see WithCodec).
+func WithExpectCodec(name string) ROption {
+	return func(r *reader) {
+		r.codec = name
+	}
+}
+
+// WithDecompressor registers custom compressor to read compressed dumps. Built-in compressors (Gzip and Flate) are
+// detected automatically.
+func WithDecompressor(c Compressor) ROption {
+	return func(r *reader) {
+		if r.comps == nil {
+			r.comps = make(map[string]Compressor)
+		}
+		r.comps[c.Name()] = c
+	}
+}
+
+// WithLegacyFormat allows reading of version 1 files, which have no header. Without it such files (as any other files
+// without header) are rejected with ErrBadHeader.
+func WithLegacyFormat() ROption {
+	return func(r *reader) {
+		r.legacy = true
+	}
+}
+
+// WithDecryption provides keys to read encrypted dumps (see WithEncryption).
+func WithDecryption(kp KeyProvider) ROption {
+	return func(r *reader) {
+		r.kp = kp
+	}
+}
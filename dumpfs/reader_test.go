@@ -1,22 +1,282 @@
 package dumpfs
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
 	"io"
-	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/koykov/ttlcache"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestReader(t *testing.T) {
-	r, _ := NewReader("testdata/example.bin", WithOnEOF(KeepFile))
+	t.Run("v1", func(t *testing.T) {
+		r, _ := NewReader("testdata/example.bin", WithOnEOF(KeepFile))
+		_, err := r.Read()
+		assert.ErrorIs(t, err, ErrBadHeader)
+
+		r, _ = NewReader("testdata/example.bin", WithOnEOF(KeepFile), WithLegacyFormat())
+		for {
+			e, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			exp := getTestBody(int(e.Key))
+			assert.Equal(t, exp, e.Body)
+			assert.Equal(t, int64(0), e.Expire)
+		}
+		assert.Equal(t, uint64(0), r.Skipped())
+	})
+	t.Run("v2", func(t *testing.T) {
+		// Build v2 file by hand, since writer supports only the latest version.
+		var rec []byte
+		for i := 0; i < 10; i++ {
+			rec = binary.LittleEndian.AppendUint64(rec, uint64(i))
+			rec = binary.LittleEndian.AppendUint32(rec, uint32(len(getTestBody(i))))
+			rec = append(rec, getTestBody(i)...)
+			rec = binary.LittleEndian.AppendUint32(rec, 0xdeadbeef)
+			rec = binary.LittleEndian.AppendUint16(rec, 1)
+			rec = binary.LittleEndian.AppendUint16(rec, 3)
+			rec = append(rec, "foo"...)
+		}
+		p := appendHeader(nil, &Header{Version: version2, Codec: "json"})
+		p = appendBlockHeader(p, 10, rec)
+		p = append(p, rec...)
+		p = appendFooter(p, 10)
+		fp := filepath.Join(t.TempDir(), "dump.bin")
+		assert.NoError(t, os.WriteFile(fp, p, 0644))
+
+		r, _ := NewReader(fp, WithOnEOF(KeepFile), WithExpectCodec("json"))
+		for i := 0; i < 10; i++ {
+			e, err := r.Read()
+			assert.NoError(t, err)
+			assert.Equal(t, getTestBody(i), e.Body)
+			assert.Equal(t, []string{"foo"}, e.Tags)
+			// Legacy expire is ignored.
+			assert.Equal(t, int64(0), e.Expire)
+		}
+		_, err := r.Read()
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Equal(t, uint64(0), r.Skipped())
+	})
+	t.Run("v3", func(t *testing.T) {
+		fp := writeTestDump(t, 100, WithCodec("json"))
+		r, _ := NewReader(fp, WithOnEOF(KeepFile), WithExpectCodec("json"))
+		n := readTestDump(t, r)
+		assert.Equal(t, 100, n)
+		assert.Equal(t, uint64(0), r.Skipped())
+	})
+	t.Run("expire", func(t *testing.T) {
+		fp := filepath.Join(t.TempDir(), "dump.bin")
+		w, err := NewWriter(fp)
+		assert.NoError(t, err)
+		// Deadline doesn't fit to 32 bits.
+		exp := time.Now().Add(time.Hour).UnixNano()
+		_, err = w.Write(ttlcache.Entry{Key: 1, Body: getTestBody(1), Expire: exp})
+		assert.NoError(t, err)
+		_, err = w.Write(ttlcache.Entry{Key: 2, Body: getTestBody(2)})
+		assert.NoError(t, err)
+		assert.NoError(t, w.Flush())
+
+		r, _ := NewReader(fp, WithOnEOF(KeepFile))
+		e, err := r.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, exp, e.Expire)
+		e, err = r.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), e.Expire)
+	})
+	t.Run("field size", func(t *testing.T) {
+		fp := filepath.Join(t.TempDir(), "dump.bin")
+		w, _ := NewWriter(fp)
+		_, err := w.Write(ttlcache.Entry{Key: 1, Body: getTestBody(1), Tags: []string{strings.Repeat("x", 1<<16)}})
+		assert.ErrorIs(t, err, ErrFieldSize)
+		_, err = w.Write(ttlcache.Entry{Key: 2, Body: getTestBody(2), Tags: make([]string, 1<<16)})
+		assert.ErrorIs(t, err, ErrFieldSize)
+		_, err = w.Write(ttlcache.Entry{Key: 3, Body: getTestBody(3), Tags: []string{"foo"}})
+		assert.NoError(t, err)
+		assert.NoError(t, w.Flush())
+		r, _ := NewReader(fp, WithOnEOF(KeepFile))
+		assert.Equal(t, 1, readTestDump(t, r))
+
+		w, _ = NewWriter(filepath.Join(t.TempDir(), "dump.bin"), WithCodec(strings.Repeat("x", 1<<16)))
+		_, err = w.Write(ttlcache.Entry{Key: 1, Body: getTestBody(1)})
+		assert.NoError(t, err)
+		assert.ErrorIs(t, w.Flush(), ErrFieldSize)
+	})
+	t.Run("codec mismatch", func(t *testing.T) {
+		fp := writeTestDump(t, 10, WithCodec("json"))
+		r, _ := NewReader(fp, WithOnEOF(KeepFile), WithExpectCodec("gob"))
+		_, err := r.Read()
+		assert.ErrorIs(t, err, ErrCodecMismatch)
+	})
+	t.Run("compression", func(t *testing.T) {
+		plain := writeTestDump(t, 1000)
+		pfi, _ := os.Stat(plain)
+		for _, c := range []Compressor{Gzip{}, Flate{Level: 9}} {
+			fp := writeTestDump(t, 1000, WithCompression(c))
+			fi, _ := os.Stat(fp)
+			assert.Less(t, fi.Size(), pfi.Size()/2, c.Name())
+			r, _ := NewReader(fp, WithOnEOF(KeepFile))
+			assert.Equal(t, 1000, readTestDump(t, r), c.Name())
+			assert.Equal(t, uint64(0), r.Skipped())
+		}
+	})
+	t.Run("decompression limit", func(t *testing.T) {
+		p, _ := Gzip{}.Compress(nil, make([]byte, 1000))
+		zr, _ := gzip.NewReader(bytes.NewReader(p))
+		_, err := readAll(nil, zr, 100)
+		assert.ErrorIs(t, err, ErrBadBlock)
+		zr, _ = gzip.NewReader(bytes.NewReader(p))
+		out, err := readAll(nil, zr, 1000)
+		assert.NoError(t, err)
+		assert.Len(t, out, 1000)
+	})
+	t.Run("custom compression", func(t *testing.T) {
+		fp := writeTestDump(t, 10, WithCompression(testNoopCompressor{}))
+		r, _ := NewReader(fp, WithOnEOF(KeepFile))
+		_, err := r.Read()
+		assert.ErrorIs(t, err, ErrCompression)
+		r, _ = NewReader(fp, WithOnEOF(KeepFile), WithDecompressor(testNoopCompressor{}))
+		assert.Equal(t, 10, readTestDump(t, r))
+	})
+	t.Run("encryption", func(t *testing.T) {
+		keys := StaticKeys{CurrentID: "k1", Keys: map[string][]byte{
+			"k1": []byte("0123456789abcdef0123456789abcdef"),
+		}}
+		fp1 := writeTestDump(t, 100, WithEncryption(keys), WithCompression(Gzip{}))
+		p, _ := os.ReadFile(fp1)
+		assert.NotContains(t, string(p), "John")
+
+		// Rotate the key, old dumps must remain readable.
+		keys.Keys["k2"] = []byte("fedcba9876543210")
+		keys.CurrentID = "k2"
+		fp2 := writeTestDump(t, 100, WithEncryption(keys))
+		for _, fp := range []string{fp1, fp2} {
+			r, _ := NewReader(fp, WithOnEOF(KeepFile), WithDecryption(keys))
+			assert.Equal(t, 100, readTestDump(t, r))
+		}
+
+		r, _ := NewReader(fp2, WithOnEOF(KeepFile))
+		_, err := r.Read()
+		assert.ErrorIs(t, err, ErrNoKey)
+
+		wrong := StaticKeys{Keys: map[string][]byte{"k2": []byte("0000000000000000")}}
+		r, _ = NewReader(fp2, WithOnEOF(KeepFile), WithDecryption(wrong))
+		_, err = r.Read()
+		assert.ErrorIs(t, err, ErrAuth)
+		_, err = r.Read()
+		assert.ErrorIs(t, err, ErrAuth)
+	})
+	t.Run("encryption tamper", func(t *testing.T) {
+		keys := StaticKeys{CurrentID: "k1", Keys: map[string][]byte{"k1": []byte("0123456789abcdef")}}
+		fp := writeTestDump(t, 100, WithEncryption(keys))
+		p, _ := os.ReadFile(fp)
+		hl, blocks := testSplitDump(p)
+		assert.Greater(t, len(blocks), 2)
+		foot := p[len(p)-footerSize-28:]
+
+		tamper := func(name string, p []byte) {
+			assert.NoError(t, os.WriteFile(fp, p, 0644))
+			r, _ := NewReader(fp, WithOnEOF(KeepFile), WithDecryption(keys))
+			var err error
+			for err == nil {
+				_, err = r.Read()
+			}
+			assert.ErrorIs(t, err, ErrAuth, name)
+		}
+		// Reordered blocks.
+		tamper("reorder", bytes.Join([][]byte{p[:hl], blocks[1], blocks[0], bytes.Join(blocks[2:], nil), foot}, nil))
+		// Dropped block.
+		tamper("drop", bytes.Join([][]byte{p[:hl], bytes.Join(blocks[:len(blocks)-1], nil), foot}, nil))
+		// Dropped tail with footer.
+		tamper("drop tail", bytes.Join([][]byte{p[:hl], bytes.Join(blocks[:len(blocks)-1], nil)}, nil))
+		// Block from other file encrypted with the same key.
+		p1, _ := os.ReadFile(writeTestDump(t, 100, WithEncryption(keys)))
+		_, blocks1 := testSplitDump(p1)
+		tamper("transplant", bytes.Join([][]byte{p[:hl], blocks1[0], bytes.Join(blocks[1:], nil), foot}, nil))
+		// Records count of the footer with valid checksum.
+		f := append([]byte{}, foot...)
+		binary.LittleEndian.PutUint64(f[1:], 99)
+		binary.LittleEndian.PutUint32(f[9:], crc32.Checksum(f[:9], crcTable))
+		tamper("footer", bytes.Join([][]byte{p[:len(p)-len(foot)], f}, nil))
+	})
+	t.Run("corrupt block", func(t *testing.T) {
+		fp := writeTestDump(t, 100)
+		p, _ := os.ReadFile(fp)
+		// Damage a body of the first record in the first block.
+		p[headerSize+4+blockHeaderSize+12+10] ^= 0xff
+		assert.NoError(t, os.WriteFile(fp, p, 0644))
+		r, _ := NewReader(fp, WithOnEOF(KeepFile))
+		n := readTestDump(t, r)
+		assert.Equal(t, uint64(100), uint64(n)+r.Skipped())
+		assert.Greater(t, r.Skipped(), uint64(0))
+	})
+	t.Run("truncated", func(t *testing.T) {
+		fp := writeTestDump(t, 100)
+		p, _ := os.ReadFile(fp)
+		assert.NoError(t, os.WriteFile(fp, p[:len(p)-footerSize-100], 0644))
+		r, _ := NewReader(fp, WithOnEOF(KeepFile))
+		n := readTestDump(t, r)
+		assert.Equal(t, uint64(100), uint64(n)+r.Skipped())
+		assert.Greater(t, r.Skipped(), uint64(0))
+	})
+}
+
+func writeTestDump(t *testing.T, n int, options ...WOption) string {
+	fp := filepath.Join(t.TempDir(), "dump.bin")
+	w, err := NewWriter(fp, append([]WOption{WithBufferSize(4096)}, options...)...)
+	assert.NoError(t, err)
+	for i := 0; i < n; i++ {
+		_, err = w.Write(ttlcache.Entry{Key: uint64(i), Body: getTestBody(i), Tags: []string{"foo"}})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Flush())
+	return fp
+}
+
+func readTestDump(t *testing.T, r Reader) (n int) {
 	for {
 		e, err := r.Read()
 		if err == io.EOF {
-			break
+			return
+		}
+		if !assert.NoError(t, err) {
+			return
 		}
-		exp := getTestBody(int(e.Key))
-		assert.Equal(t, exp, e.Body)
-		assert.NotEqual(t, math.MaxUint32, e.Expire)
+		assert.Equal(t, getTestBody(int(e.Key)), e.Body)
+		assert.Equal(t, []string{"foo"}, e.Tags)
+		n++
+	}
+}
+
+// Split dump file to header length and blocks.
+func testSplitDump(p []byte) (hl int, blocks [][]byte) {
+	hl = headerSize
+	for i := 0; i < int(p[headerSize-1]); i++ {
+		hl += 3 + int(binary.LittleEndian.Uint16(p[hl+1:]))
 	}
+	hl += 4
+	for off := hl; p[off] == blockKind; {
+		l := blockHeaderSize + int(binary.LittleEndian.Uint32(p[off+1:]))
+		blocks = append(blocks, p[off:off+l])
+		off += l
+	}
+	return
 }
+
+// Compressor that keeps data as is.
+type testNoopCompressor struct{}
+
+func (testNoopCompressor) Name() string { return "noop" }
+
+func (testNoopCompressor) Compress(dst, p []byte) ([]byte, error) { return append(dst, p...), nil }
+
+func (testNoopCompressor) Decompress(dst, p []byte) ([]byte, error) { return append(dst, p...), nil }
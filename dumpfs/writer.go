@@ -1,13 +1,12 @@
 package dumpfs
 
 import (
-	"encoding/binary"
+	"crypto/cipher"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
-	"github.com/koykov/bytealg"
 	"github.com/koykov/byteconv"
 	"github.com/koykov/clock"
 	"github.com/koykov/ttlcache"
@@ -18,18 +17,35 @@ type Writer interface {
 	Flush() error
 }
 
-const defaultBlockSIze = 4096
+const (
+	defaultBlockSIze  = 4096
+	defaultBufferSize = 64 * 1024
+)
 
 type writer struct {
-	bs  uint64
-	fp  string
-	fd  string
-	ft  string
-	bsz int64
-
-	mux sync.Mutex
-	f   *os.File
-	buf []byte
+	bs    uint64
+	fp    string
+	fd    string
+	ft    string
+	bsz   int64
+	codec string
+	comp  Compressor
+	kp    KeyProvider
+	aead  cipher.AEAD
+
+	mux  sync.Mutex
+	f    *os.File
+	buf  []byte
+	hbuf []byte
+	cbuf []byte
+	ebuf []byte
+	// Header of the current file and additional data of encrypted block.
+	hdr, ad []byte
+	// Records in the buffer and in the file.
+	nb uint32
+	nf uint64
+	// Blocks in the file.
+	seq uint64
 
 	err error
 }
@@ -49,23 +65,13 @@ func (w *writer) Write(entry ttlcache.Entry) (n int, err error) {
 	w.mux.Lock()
 	defer w.mux.Unlock()
 
-	off := len(w.buf)
-	poff := off
-	w.buf = bytealg.GrowDelta(w.buf, 8)
-	binary.LittleEndian.PutUint64(w.buf[off:], entry.Key)
-	off += 8
-
-	w.buf = bytealg.GrowDelta(w.buf, 4)
-	binary.LittleEndian.PutUint32(w.buf[off:], uint32(len(entry.Body)))
-	off += 4
-	w.buf = append(w.buf, entry.Body...)
-	off += len(entry.Body)
-
-	w.buf = bytealg.GrowDelta(w.buf, 4)
-	binary.LittleEndian.PutUint32(w.buf[off:], entry.Expire)
-	off += 4
-
-	n = off - poff
+	if err = checkRecord(&entry); err != nil {
+		return
+	}
+	poff := len(w.buf)
+	w.buf = appendRecord(w.buf, &entry)
+	n = len(w.buf) - poff
+	w.nb++
 
 	if uint64(len(w.buf)) >= w.bs {
 		err = w.flushBuf()
@@ -83,6 +89,24 @@ func (w *writer) Flush() (err error) {
 			return
 		}
 	}
+	if w.f == nil {
+		// Nothing written, but empty dump is still valid.
+		if err = w.open(); err != nil {
+			return
+		}
+	}
+
+	w.hbuf = appendFooter(w.hbuf[:0], w.nf)
+	if w.aead != nil {
+		w.ad = appendAD(w.ad[:0], w.hdr, footerKind, w.seq, w.nf)
+		if w.hbuf, err = sealBlock(w.hbuf, w.aead, w.ad, nil); err != nil {
+			return
+		}
+	}
+	if _, err = w.f.Write(w.hbuf); err != nil {
+		return
+	}
+	w.nf, w.seq = 0, 0
 
 	if err = w.f.Close(); err != nil {
 		return
@@ -105,26 +129,77 @@ func (w *writer) init() error {
 	if w.bsz = blockSizeOf(dir); w.bsz == 0 {
 		w.bsz = defaultBlockSIze
 	}
-	if w.bs > 0 {
-		w.buf = make([]byte, 0, w.bs)
+	if w.bs == 0 {
+		w.bs = defaultBufferSize
 	}
+	w.buf = make([]byte, 0, w.bs)
 	return nil
 }
 
-func (w *writer) flushBuf() (err error) {
-	if w.f == nil {
-		buf := make([]byte, 0, len(w.fp)*2)
-		if buf, err = clock.AppendFormat(buf, w.fp, time.Now()); err != nil {
+// Create new dump file and write the header.
+func (w *writer) open() (err error) {
+	now := time.Now()
+	buf := make([]byte, 0, len(w.fp)*2)
+	if buf, err = clock.AppendFormat(buf, w.fp, now); err != nil {
+		return
+	}
+	hdr := Header{
+		Version: version3,
+		Created: now.UnixNano(),
+		Codec:   w.codec,
+	}
+	if w.comp != nil {
+		hdr.Compression = w.comp.Name()
+	}
+	if w.kp != nil {
+		// Key may change between dumps due to rotation.
+		var key []byte
+		if hdr.KeyID, key, err = w.kp.Current(); err != nil {
 			return
 		}
-		w.fd = byteconv.B2S(buf)
-		w.ft = w.fd + ".tmp"
-		if w.f, err = os.Create(w.ft); err != nil {
+		if w.aead, err = newAEAD(key); err != nil {
+			return
+		}
+	}
+	if err = checkHeader(&hdr); err != nil {
+		return
+	}
+	w.fd = byteconv.B2S(buf)
+	w.ft = w.fd + ".tmp"
+	if w.f, err = os.Create(w.ft); err != nil {
+		return
+	}
+	w.hdr = appendHeader(w.hdr[:0], &hdr)
+	_, err = w.f.Write(w.hdr)
+	return
+}
+
+// Write buffered records as a block.
+func (w *writer) flushBuf() (err error) {
+	if w.f == nil {
+		if err = w.open(); err != nil {
 			return
 		}
 	}
 
 	p := w.buf
+	if w.comp != nil {
+		if w.cbuf, err = w.comp.Compress(w.cbuf[:0], w.buf); err != nil {
+			return
+		}
+		p = w.cbuf
+	}
+	if w.aead != nil {
+		w.ad = appendAD(w.ad[:0], w.hdr, blockKind, w.seq, uint64(w.nb))
+		if w.ebuf, err = sealBlock(w.ebuf[:0], w.aead, w.ad, p); err != nil {
+			return
+		}
+		p = w.ebuf
+	}
+	w.hbuf = appendBlockHeader(w.hbuf[:0], w.nb, p)
+	if _, err = w.f.Write(w.hbuf); err != nil {
+		return
+	}
 	for len(p) >= int(w.bsz) {
 		if _, err = w.f.Write(p[:w.bsz]); err != nil {
 			return
@@ -137,5 +212,8 @@ func (w *writer) flushBuf() (err error) {
 		}
 	}
 	w.buf = w.buf[:0]
+	w.nf += uint64(w.nb)
+	w.nb = 0
+	w.seq++
 	return
 }
@@ -7,3 +7,24 @@ func WithBufferSize(bufferSize uint64) WOption {
 		w.bs = bufferSize
 	}
 }
+
+// WithCodec writes codec name to the dump header. Reader may check it using WithExpectCodec.
+func WithCodec(name string) WOption {
+	return func(w *writer) {
+		w.codec = name
+	}
+}
+
+// WithCompression compresses blocks of the dump using given compressor (see Gzip and Flate).
+func WithCompression(c Compressor) WOption {
+	return func(w *writer) {
+		w.comp = c
+	}
+}
+
+// WithEncryption encrypts blocks of the dump using AES-GCM with the current key of the provider.
+func WithEncryption(kp KeyProvider) WOption {
+	return func(w *writer) {
+		w.kp = kp
+	}
+}
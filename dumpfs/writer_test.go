@@ -1,8 +1,8 @@
 package dumpfs
 
 import (
-	"math"
 	"testing"
+	"time"
 
 	"github.com/koykov/ttlcache"
 	"github.com/stretchr/testify/assert"
@@ -31,7 +31,7 @@ func TestWriter(t *testing.T) {
 		e := ttlcache.Entry{
 			Key:    uint64(i),
 			Body:   body,
-			Expire: math.MaxUint32,
+			Expire: time.Now().Add(time.Hour).UnixNano(),
 		}
 		_, err := w.Write(e)
 		assert.NoError(t, err)
@@ -1,7 +1,76 @@
 package ttlcache
 
-type entry[T any] struct {
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EntryInfo represents cached value with its expiration metadata.
+type EntryInfo[T any] struct {
+	Value T
+	// Inserted is a time when the entry was set.
+	Inserted time.Time
+	// ExpiresAt is an entry's deadline. Zero if the entry never expires.
+	ExpiresAt time.Time
+	// TTL is a remaining lifetime of the entry. Zero if the entry never expires.
+	TTL time.Duration
+	// Refresh reports that the entry should be refreshed before its deadline (see Config.EarlyRefresh).
+	Refresh bool
+}
+
+type entry[K comparable, T any] struct {
 	payload   T
+	key       K
 	hkey      uint64
 	timestamp int64
+	expire    int64
+	ttl       int64
+	delta     int64
+	missing   bool
+	tags      []string
+	// Earliest time of the next background refresh (see Config.RefreshBackoff).
+	refresh int64
+}
+
+// Check if entry's deadline has come.
+func (e *entry[K, T]) expired(now int64) bool {
+	exp := e.deadline()
+	return exp > 0 && exp < now
+}
+
+// Get entry's deadline. Sliding expiration moves deadline under read lock, thus it must be read atomically.
+func (e *entry[K, T]) deadline() int64 {
+	return atomic.LoadInt64(&e.expire)
+}
+
+// Move deadline forward. Safe under read lock, concurrent readers can't move deadline back.
+func (e *entry[K, T]) prolong(expire int64) {
+	for {
+		old := atomic.LoadInt64(&e.expire)
+		if expire <= old || atomic.CompareAndSwapInt64(&e.expire, old, expire) {
+			return
+		}
+	}
+}
+
+// Mark the entry as being refreshed. Returns false if other refresh started recently. Safe under read lock.
+func (e *entry[K, T]) markRefresh(now, backoff int64) bool {
+	next := atomic.LoadInt64(&e.refresh)
+	return next <= now && atomic.CompareAndSwapInt64(&e.refresh, next, now+backoff)
+}
+
+// Copy the entry. Safe under read lock.
+func (e *entry[K, T]) copy() entry[K, T] {
+	return entry[K, T]{
+		payload:   e.payload,
+		key:       e.key,
+		hkey:      e.hkey,
+		timestamp: e.timestamp,
+		expire:    e.deadline(),
+		ttl:       e.ttl,
+		delta:     e.delta,
+		missing:   e.missing,
+		tags:      e.tags,
+		refresh:   atomic.LoadInt64(&e.refresh),
+	}
 }
@@ -13,4 +13,9 @@ var (
 	ErrNotFound          = errors.New("entry not found")
 	ErrExpire            = errors.New("entry expired")
 	ErrOverflow          = errors.New("cache overflow")
+	ErrCollision         = errors.New("key hash collision")
+	ErrCachedMiss        = errors.New("entry cached as missing")
+	ErrNoKeys            = errors.New("operation requires stored keys, see Config.StoreKeys")
+	ErrKeyKind           = errors.New("operation requires keys of string kind")
+	ErrDumpKeys          = errors.New("dump doesn't support stored keys, see Config.StoreKeys")
 )
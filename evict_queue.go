@@ -0,0 +1,104 @@
+package ttlcache
+
+import "sync"
+
+// EvictReason describes why the entry left the cache.
+type EvictReason uint8
+
+const (
+	// EvictReasonExpired means entry evicted due to TTL.
+	EvictReasonExpired EvictReason = iota
+	// EvictReasonCapacity means entry evicted by eviction policy to free space.
+	EvictReasonCapacity
+	// EvictReasonDeleted means entry deleted using Delete.
+	EvictReasonDeleted
+	// EvictReasonExtracted means entry extracted using Extract.
+	EvictReasonExtracted
+	// EvictReasonReset means entry dropped by Reset.
+	EvictReasonReset
+	// EvictReasonClosed means entry dropped by Close.
+	EvictReasonClosed
+	// EvictReasonReplaced means entry overwritten by new value of the same key.
+	EvictReasonReplaced
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictReasonExpired:
+		return "expired"
+	case EvictReasonCapacity:
+		return "capacity"
+	case EvictReasonDeleted:
+		return "deleted"
+	case EvictReasonExtracted:
+		return "extracted"
+	case EvictReasonReset:
+		return "reset"
+	case EvictReasonClosed:
+		return "closed"
+	case EvictReasonReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+type evictEvent[T any] struct {
+	hkey    uint64
+	payload T
+	reason  EvictReason
+}
+
+// Bounded queue of eviction events. Delivers events to OnEvict callback in separate goroutine, so slow callback
+// can't stall the cache. Events that don't fit to the queue are dropped.
+type evictQueue[T any] struct {
+	fn     func(key uint64, value T, reason EvictReason)
+	mux    sync.RWMutex
+	c      chan evictEvent[T]
+	done   chan struct{}
+	closed bool
+}
+
+func newEvictQueue[T any](fn func(key uint64, value T, reason EvictReason), size uint) *evictQueue[T] {
+	q := &evictQueue[T]{
+		fn:   fn,
+		c:    make(chan evictEvent[T], size),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(q.done)
+		for e := range q.c {
+			q.fn(e.hkey, e.payload, e.reason)
+		}
+	}()
+	return q
+}
+
+// Send event to the queue. Never blocks, thus callback may use the cache. Returns false if queue is full and event
+// is dropped.
+func (q *evictQueue[T]) push(e evictEvent[T]) bool {
+	q.mux.RLock()
+	defer q.mux.RUnlock()
+	if q.closed {
+		return true
+	}
+	select {
+	case q.c <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close the queue and wait till all pending events will be delivered.
+func (q *evictQueue[T]) close() {
+	q.mux.Lock()
+	if q.closed {
+		q.mux.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.c)
+	q.mux.Unlock()
+	<-q.done
+}
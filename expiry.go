@@ -0,0 +1,78 @@
+package ttlcache
+
+import "container/heap"
+
+// Expiration index of the bucket. Min-heap of entries deadlines, so eviction touches only entries that are due.
+type expiry struct {
+	buf []expiryItem
+	idx map[uint64]int
+}
+
+type expiryItem struct {
+	hkey   uint64
+	expire int64
+}
+
+// Register or update deadline of the key. Non-positive expire means entry never expires (see entry.expired).
+func (x *expiry) set(hkey uint64, expire int64) {
+	if expire <= 0 {
+		x.remove(hkey)
+		return
+	}
+	if x.idx == nil {
+		x.idx = make(map[uint64]int)
+	}
+	if i, ok := x.idx[hkey]; ok {
+		x.buf[i].expire = expire
+		heap.Fix(x, i)
+		return
+	}
+	heap.Push(x, expiryItem{hkey: hkey, expire: expire})
+}
+
+func (x *expiry) remove(hkey uint64) {
+	if i, ok := x.idx[hkey]; ok {
+		heap.Remove(x, i)
+	}
+}
+
+// Get the nearest deadline.
+func (x *expiry) peek() (expiryItem, bool) {
+	if len(x.buf) == 0 {
+		return expiryItem{}, false
+	}
+	return x.buf[0], true
+}
+
+func (x *expiry) reset() {
+	x.buf = x.buf[:0]
+	for k := range x.idx {
+		delete(x.idx, k)
+	}
+}
+
+// heap.Interface implementation.
+
+func (x *expiry) Len() int { return len(x.buf) }
+
+func (x *expiry) Less(i, j int) bool { return x.buf[i].expire < x.buf[j].expire }
+
+func (x *expiry) Swap(i, j int) {
+	x.buf[i], x.buf[j] = x.buf[j], x.buf[i]
+	x.idx[x.buf[i].hkey] = i
+	x.idx[x.buf[j].hkey] = j
+}
+
+func (x *expiry) Push(v any) {
+	itm := v.(expiryItem)
+	x.idx[itm.hkey] = len(x.buf)
+	x.buf = append(x.buf, itm)
+}
+
+func (x *expiry) Pop() any {
+	l := len(x.buf)
+	itm := x.buf[l-1]
+	x.buf = x.buf[:l-1]
+	delete(x.idx, itm.hkey)
+	return itm
+}
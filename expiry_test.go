@@ -0,0 +1,60 @@
+package ttlcache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/koykov/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiry(t *testing.T) {
+	t.Run("order", func(t *testing.T) {
+		var x expiry
+		x.set(1, 30)
+		x.set(2, 10)
+		x.set(3, 20)
+		x.set(4, 0)
+		x.set(5, -10)
+		itm, _ := x.peek()
+		assert.Equal(t, uint64(2), itm.hkey)
+		x.set(2, 40)
+		itm, _ = x.peek()
+		assert.Equal(t, uint64(3), itm.hkey)
+		x.remove(3)
+		itm, _ = x.peek()
+		assert.Equal(t, uint64(1), itm.hkey)
+		x.set(1, 0)
+		itm, _ = x.peek()
+		assert.Equal(t, uint64(2), itm.hkey)
+		x.reset()
+		_, ok := x.peek()
+		assert.False(t, ok)
+	})
+	t.Run("evict", func(t *testing.T) {
+		clk := clock.NewClock()
+		cache, err := New[testEntry](&Config[testEntry]{
+			Buckets:       2,
+			Hasher:        testHasher{},
+			TTLInterval:   time.Hour,
+			EvictInterval: time.Minute,
+			Clock:         clk,
+		})
+		assert.NoError(t, err)
+		const n = evictSliceSize * 3
+		for i := 0; i < n; i++ {
+			assert.NoError(t, cache.SetWithTTL(strconv.Itoa(i), testEntry{p: getEntryBody(i)}, time.Duration(i%2+1)*time.Minute))
+		}
+		clk.Jump(90 * time.Second)
+		for i := 0; i < n; i++ {
+			_, err = cache.Get(strconv.Itoa(i))
+			if i%2 == 0 {
+				assert.ErrorIs(t, err, ErrNotFound)
+			} else {
+				assert.NoError(t, err)
+			}
+		}
+		assert.NoError(t, cache.Close())
+	})
+}
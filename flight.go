@@ -0,0 +1,85 @@
+package ttlcache
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// LoadFunc loads value missing in the cache. Returns value and its TTL (zero TTL means default TTL interval).
+type LoadFunc[T any] func(ctx context.Context) (T, time.Duration, error)
+
+// KLoader loads the value by key. Uses to refresh stale entries (see Config.StaleTTL).
+type KLoader[K comparable, T any] func(ctx context.Context, key K) (T, time.Duration, error)
+
+// Loader is a loader of string keys.
+type Loader[T any] = KLoader[string, T]
+
+// LoadPanicError reports that loader panicked. Loader runs in background goroutine, thus panic recovers to keep the
+// process alive and returns to all waiting callers.
+type LoadPanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *LoadPanicError) Error() string {
+	return fmt.Sprintf("loader panicked: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Group of in-flight loads. Guarantees that only one load per key executes at the same time.
+type flight[K comparable, T any] struct {
+	mux   sync.Mutex
+	calls map[flightKey[K]]*flightCall[T]
+}
+
+// Key of in-flight load. Original key makes sense only if keys storing enabled, otherwise loads of colliding keys are
+// indistinguishable anyway.
+type flightKey[K comparable] struct {
+	hkey uint64
+	key  K
+}
+
+type flightCall[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// Execute fn once per key and wait for result.
+//
+// fn runs in a separate goroutine with context detached from cancellation, so cancellation of one caller doesn't
+// break the load for others. Each caller may stop waiting using its own ctx.
+func (f *flight[K, T]) do(ctx context.Context, fk flightKey[K], fn func(ctx context.Context) (T, error)) (T, error) {
+	f.mux.Lock()
+	if f.calls == nil {
+		f.calls = make(map[flightKey[K]]*flightCall[T])
+	}
+	call, ok := f.calls[fk]
+	if !ok {
+		call = &flightCall[T]{done: make(chan struct{})}
+		f.calls[fk] = call
+		go func(ctx context.Context) {
+			defer func() {
+				if r := recover(); r != nil {
+					call.err = &LoadPanicError{Value: r, Stack: debug.Stack()}
+				}
+				f.mux.Lock()
+				delete(f.calls, fk)
+				f.mux.Unlock()
+				close(call.done)
+			}()
+			call.val, call.err = fn(ctx)
+		}(context.WithoutCancel(ctx))
+	}
+	f.mux.Unlock()
+
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		var null T
+		return null, ctx.Err()
+	}
+}
@@ -1,5 +1,51 @@
 package ttlcache
 
-type Hasher interface {
-	Sum64(string) uint64
+import (
+	"reflect"
+	"strings"
+)
+
+// KeyHasher makes 64-bit hash of the key.
+type KeyHasher[K comparable] interface {
+	Sum64(K) uint64
+}
+
+// Hasher is a hasher of string keys.
+type Hasher = KeyHasher[string]
+
+// Integer is a constraint of all integer kinds.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// IntHasher is a fast hasher of integer keys. Mixes bits (splitmix64 finalizer), so sequential keys spread over
+// buckets uniformly.
+type IntHasher[K Integer] struct{}
+
+func (IntHasher[K]) Sum64(key K) uint64 {
+	x := uint64(key)
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Make a copy of the key if it may point to reusable memory. Only keys of string kinds copies, strings nested in
+// struct keys are the caller's responsibility.
+func cloneKey[K comparable](key K) K {
+	if s, ok := any(key).(string); ok {
+		return any(strings.Clone(s)).(K)
+	}
+	if stringKey[K]() {
+		v := reflect.ValueOf(&key).Elem()
+		v.SetString(strings.Clone(v.String()))
+	}
+	return key
+}
+
+// Check if keys of type K are strings.
+func stringKey[K comparable]() bool {
+	return reflect.TypeFor[K]().Kind() == reflect.String
 }
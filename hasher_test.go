@@ -16,3 +16,19 @@ func (testHasher) Sum64(s string) uint64 {
 	}
 	return h
 }
+
+// Hasher with guaranteed collisions.
+type testCollideHasher struct{}
+
+func (testCollideHasher) Sum64(_ string) uint64 {
+	return 1
+}
+
+// Key type of string kind.
+type userID string
+
+type userIDHasher struct{}
+
+func (userIDHasher) Sum64(s userID) uint64 {
+	return testHasher{}.Sum64(string(s))
+}
@@ -0,0 +1,19 @@
+package ttlcache
+
+import "math/rand/v2"
+
+// Randomly shorten ttl within configured jitter range to spread out mass expirations.
+func jitter[K comparable, T any](conf *KConfig[K, T], ttl int64) int64 {
+	if ttl <= 0 || (conf.TTLJitter <= 0 && conf.TTLJitterRatio <= 0) {
+		return ttl
+	}
+	j := int64(conf.TTLJitter) + int64(float64(ttl)*conf.TTLJitterRatio)
+	if j >= ttl {
+		// Keep at least small piece of TTL.
+		j = ttl - 1
+	}
+	if j <= 0 {
+		return ttl
+	}
+	return ttl - rand.Int64N(j+1)
+}
@@ -0,0 +1,33 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitter(t *testing.T) {
+	check := func(t *testing.T, conf *Config[testEntry], lo, hi time.Duration) {
+		uniq := make(map[int64]struct{})
+		for i := 0; i < 1000; i++ {
+			ttl := jitter(conf, int64(time.Minute))
+			assert.GreaterOrEqual(t, ttl, int64(lo))
+			assert.LessOrEqual(t, ttl, int64(hi))
+			uniq[ttl] = struct{}{}
+		}
+		assert.Greater(t, len(uniq), 1)
+	}
+	t.Run("absolute", func(t *testing.T) {
+		check(t, &Config[testEntry]{TTLJitter: 10 * time.Second}, 50*time.Second, time.Minute)
+	})
+	t.Run("ratio", func(t *testing.T) {
+		check(t, &Config[testEntry]{TTLJitterRatio: 0.5}, 30*time.Second, time.Minute)
+	})
+	t.Run("overflow", func(t *testing.T) {
+		check(t, &Config[testEntry]{TTLJitter: time.Hour}, 1, time.Minute)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		assert.Equal(t, int64(time.Minute), jitter(&Config[testEntry]{}, int64(time.Minute)))
+	})
+}
@@ -1,6 +1,9 @@
 package ttlcache
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type MetricsWriter interface {
 	Set(bucket string, dur time.Duration)
@@ -8,11 +11,27 @@ type MetricsWriter interface {
 	Delete(bucket string)
 	Extract(bucket string)
 	Miss(bucket string)
+	CachedMiss(bucket string)
 	Expire(bucket string)
 	Overflow(bucket string)
+	Collision(bucket string)
+	Admit(bucket string)
+	Reject(bucket string)
 	Evict(bucket string)
+	// EvictDrop reports eviction event dropped since OnEvict queue is full.
+	EvictDrop(bucket string)
 	Dump(bucket string)
 	Load(bucket string)
+	// LoadSkip reports entry from dump skipped since it already expired.
+	LoadSkip(bucket string)
+	// LoadFail reports entry from dump that can't be decoded or stored.
+	LoadFail(bucket string)
+}
+
+// ContextMetricsWriter is an optional extension of MetricsWriter. Context-aware methods (GetCtx, SetCtx, ...) and
+// GetOrLoad report metrics using writer bound to the context, so it may carry trace metadata.
+type ContextMetricsWriter interface {
+	WithContext(ctx context.Context) MetricsWriter
 }
 
 type dummyMW struct{}
@@ -22,8 +41,15 @@ func (dummyMW) Hit(_ string, _ time.Duration) {}
 func (dummyMW) Delete(_ string)               {}
 func (dummyMW) Extract(_ string)              {}
 func (dummyMW) Miss(_ string)                 {}
+func (dummyMW) CachedMiss(_ string)           {}
 func (dummyMW) Expire(_ string)               {}
 func (dummyMW) Overflow(_ string)             {}
+func (dummyMW) Collision(_ string)            {}
+func (dummyMW) Admit(_ string)                {}
+func (dummyMW) Reject(_ string)               {}
 func (dummyMW) Evict(_ string)                {}
+func (dummyMW) EvictDrop(_ string)            {}
 func (dummyMW) Dump(_ string)                 {}
 func (dummyMW) Load(_ string)                 {}
+func (dummyMW) LoadSkip(_ string)             {}
+func (dummyMW) LoadFail(_ string)             {}
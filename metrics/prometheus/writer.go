@@ -9,18 +9,25 @@ import (
 const (
 	cacheIOSet     = "set"
 	cacheIOEvict   = "evict"
+	cacheIOEvDrop  = "evict drop"
 	cacheIOMiss    = "miss"
+	cacheIONegHit  = "cached miss"
 	cacheIOHit     = "hit"
 	cacheIODelete  = "delete"
 	cacheIOExtract = "extract"
 	cacheIOExpire  = "expire"
 	cacheIONoSpace = "no space"
+	cacheIOCollide = "collision"
+	cacheIOAdmit   = "admit"
+	cacheIOReject  = "reject"
 
 	speedWrite = "write"
 	speedRead  = "read"
 
 	dumpIODump = "dump"
 	dumpIOLoad = "load"
+	dumpIOSkip = "load skip"
+	dumpIOFail = "load fail"
 )
 
 type Writer interface {
@@ -29,11 +36,18 @@ type Writer interface {
 	Delete(bucket string)
 	Extract(bucket string)
 	Miss(bucket string)
+	CachedMiss(bucket string)
 	Expire(bucket string)
 	Overflow(bucket string)
+	Collision(bucket string)
+	Admit(bucket string)
+	Reject(bucket string)
 	Evict(bucket string)
+	EvictDrop(bucket string)
 	Dump(bucket string)
 	Load(bucket string)
+	LoadSkip(bucket string)
+	LoadFail(bucket string)
 }
 
 type writer struct {
@@ -77,6 +91,10 @@ func (w *writer) Miss(bucket string) {
 	io.WithLabelValues(w.key, bucket, cacheIOMiss).Inc()
 }
 
+func (w *writer) CachedMiss(bucket string) {
+	io.WithLabelValues(w.key, bucket, cacheIONegHit).Inc()
+}
+
 func (w *writer) Expire(bucket string) {
 	io.WithLabelValues(w.key, bucket, cacheIOExpire).Inc()
 }
@@ -85,11 +103,27 @@ func (w *writer) Overflow(bucket string) {
 	io.WithLabelValues(w.key, bucket, cacheIONoSpace).Inc()
 }
 
+func (w *writer) Collision(bucket string) {
+	io.WithLabelValues(w.key, bucket, cacheIOCollide).Inc()
+}
+
+func (w *writer) Admit(bucket string) {
+	io.WithLabelValues(w.key, bucket, cacheIOAdmit).Inc()
+}
+
+func (w *writer) Reject(bucket string) {
+	io.WithLabelValues(w.key, bucket, cacheIOReject).Inc()
+}
+
 func (w *writer) Evict(bucket string) {
 	size.WithLabelValues(w.key, bucket).Dec()
 	io.WithLabelValues(w.key, bucket, cacheIOEvict).Inc()
 }
 
+func (w *writer) EvictDrop(bucket string) {
+	io.WithLabelValues(w.key, bucket, cacheIOEvDrop).Inc()
+}
+
 func (w *writer) Dump(bucket string) {
 	dumpIO.WithLabelValues(w.key, bucket, dumpIODump).Inc()
 }
@@ -98,6 +132,14 @@ func (w *writer) Load(bucket string) {
 	dumpIO.WithLabelValues(w.key, bucket, dumpIOLoad).Inc()
 }
 
+func (w *writer) LoadSkip(bucket string) {
+	dumpIO.WithLabelValues(w.key, bucket, dumpIOSkip).Inc()
+}
+
+func (w *writer) LoadFail(bucket string) {
+	dumpIO.WithLabelValues(w.key, bucket, dumpIOFail).Inc()
+}
+
 var (
 	size       *prometheus.GaugeVec
 	io, dumpIO *prometheus.CounterVec
@@ -9,18 +9,25 @@ import (
 const (
 	cacheIOSet     = "set"
 	cacheIOEvict   = "evict"
+	cacheIOEvDrop  = "evict drop"
 	cacheIOMiss    = "miss"
+	cacheIONegHit  = "cached miss"
 	cacheIOHit     = "hit"
 	cacheIODelete  = "delete"
 	cacheIOExtract = "extract"
 	cacheIOExpire  = "expire"
 	cacheIONoSpace = "no space"
+	cacheIOCollide = "collision"
+	cacheIOAdmit   = "admit"
+	cacheIOReject  = "reject"
 
 	speedWrite = "write"
 	speedRead  = "read"
 
 	dumpIODump = "dump"
 	dumpIOLoad = "load"
+	dumpIOSkip = "load skip"
+	dumpIOFail = "load fail"
 )
 
 type Writer interface {
@@ -29,11 +36,18 @@ type Writer interface {
 	Delete(bucket string)
 	Extract(bucket string)
 	Miss(bucket string)
+	CachedMiss(bucket string)
 	Expire(bucket string)
 	Overflow(bucket string)
+	Collision(bucket string)
+	Admit(bucket string)
+	Reject(bucket string)
 	Evict(bucket string)
+	EvictDrop(bucket string)
 	Dump(bucket string)
 	Load(bucket string)
+	LoadSkip(bucket string)
+	LoadFail(bucket string)
 }
 
 type writer struct {
@@ -104,6 +118,13 @@ func (w *writer) Miss(bucket string) {
 		WithLabel("op", cacheIOMiss).Inc()
 }
 
+func (w *writer) CachedMiss(bucket string) {
+	vmchain.Counter("ttlcache_io").
+		WithLabel("cache", w.key).
+		WithLabel("bucket", bucket).
+		WithLabel("op", cacheIONegHit).Inc()
+}
+
 func (w *writer) Expire(bucket string) {
 	vmchain.Counter("ttlcache_io").
 		WithLabel("cache", w.key).
@@ -118,6 +139,27 @@ func (w *writer) Overflow(bucket string) {
 		WithLabel("op", cacheIONoSpace).Inc()
 }
 
+func (w *writer) Collision(bucket string) {
+	vmchain.Counter("ttlcache_io").
+		WithLabel("cache", w.key).
+		WithLabel("bucket", bucket).
+		WithLabel("op", cacheIOCollide).Inc()
+}
+
+func (w *writer) Admit(bucket string) {
+	vmchain.Counter("ttlcache_io").
+		WithLabel("cache", w.key).
+		WithLabel("bucket", bucket).
+		WithLabel("op", cacheIOAdmit).Inc()
+}
+
+func (w *writer) Reject(bucket string) {
+	vmchain.Counter("ttlcache_io").
+		WithLabel("cache", w.key).
+		WithLabel("bucket", bucket).
+		WithLabel("op", cacheIOReject).Inc()
+}
+
 func (w *writer) Evict(bucket string) {
 	vmchain.Gauge("ttlcache_size", nil).
 		WithLabel("cache", w.key).
@@ -128,6 +170,13 @@ func (w *writer) Evict(bucket string) {
 		WithLabel("op", cacheIOEvict).Inc()
 }
 
+func (w *writer) EvictDrop(bucket string) {
+	vmchain.Counter("ttlcache_io").
+		WithLabel("cache", w.key).
+		WithLabel("bucket", bucket).
+		WithLabel("op", cacheIOEvDrop).Inc()
+}
+
 func (w *writer) Dump(bucket string) {
 	vmchain.Counter("ttlcache_dump_io").
 		WithLabel("cache", w.key).
@@ -142,4 +191,18 @@ func (w *writer) Load(bucket string) {
 		WithLabel("op", dumpIOLoad).Inc()
 }
 
+func (w *writer) LoadSkip(bucket string) {
+	vmchain.Counter("ttlcache_dump_io").
+		WithLabel("cache", w.key).
+		WithLabel("bucket", bucket).
+		WithLabel("op", dumpIOSkip).Inc()
+}
+
+func (w *writer) LoadFail(bucket string) {
+	vmchain.Counter("ttlcache_dump_io").
+		WithLabel("cache", w.key).
+		WithLabel("bucket", bucket).
+		WithLabel("op", dumpIOFail).Inc()
+}
+
 var _ = NewWriter
@@ -0,0 +1,32 @@
+package ttlcache
+
+// EvictionPolicy describes capacity eviction policy. Applies when bucket reaches its size limit to free space for
+// new entry instead of ErrOverflow.
+type EvictionPolicy interface {
+	// Instance makes new policy state for the bucket of given size.
+	Instance(size uint64) Policy
+}
+
+// Policy represents bucket-level state of eviction policy.
+//
+// Bucket guarantees exclusive access to the policy, so implementations may be not thread-safe.
+type Policy interface {
+	// Add registers new key.
+	Add(hkey uint64)
+	// Touch registers access to the key. Key may be absent in the bucket (cache miss).
+	Touch(hkey uint64)
+	// Remove unregisters the key.
+	Remove(hkey uint64)
+	// Victim returns the key to evict to free space.
+	Victim() (uint64, bool)
+	// Reset clears the state.
+	Reset()
+}
+
+// Admitter is an optional extension of Policy with admission filter (see TinyLFU).
+type Admitter interface {
+	// Candidate returns the key that claims the place of victim.
+	Candidate() (uint64, bool)
+	// Admit decides whether candidate may replace victim. The loser must be evicted by the bucket.
+	Admit(candidate, victim uint64) bool
+}
@@ -0,0 +1,14 @@
+package ttlcache
+
+import "container/list"
+
+// FIFO is a "first in, first out" eviction policy. Evicts the oldest entry regardless of access.
+type FIFO struct{}
+
+func (FIFO) Instance(size uint64) Policy {
+	return &lru{
+		ll:   list.New(),
+		idx:  make(map[uint64]*list.Element, size),
+		fifo: true,
+	}
+}
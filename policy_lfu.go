@@ -0,0 +1,99 @@
+package ttlcache
+
+import "container/list"
+
+// LFU is a "least frequently used" eviction policy. Evicts the oldest entry among least frequently used.
+type LFU struct{}
+
+func (LFU) Instance(size uint64) Policy {
+	return &lfu{
+		idx:  make(map[uint64]*lfuItem, size),
+		freq: make(map[uint64]*list.List),
+	}
+}
+
+type lfu struct {
+	idx  map[uint64]*lfuItem
+	freq map[uint64]*list.List
+	min  uint64
+}
+
+type lfuItem struct {
+	hkey uint64
+	freq uint64
+	e    *list.Element
+}
+
+func (p *lfu) Add(hkey uint64) {
+	if _, ok := p.idx[hkey]; ok {
+		p.Touch(hkey)
+		return
+	}
+	itm := &lfuItem{hkey: hkey, freq: 1}
+	itm.e = p.list(1).PushFront(itm)
+	p.idx[hkey] = itm
+	p.min = 1
+}
+
+func (p *lfu) Touch(hkey uint64) {
+	itm, ok := p.idx[hkey]
+	if !ok {
+		return
+	}
+	p.unlink(itm)
+	itm.freq++
+	itm.e = p.list(itm.freq).PushFront(itm)
+}
+
+func (p *lfu) Remove(hkey uint64) {
+	if itm, ok := p.idx[hkey]; ok {
+		p.unlink(itm)
+		delete(p.idx, hkey)
+	}
+}
+
+func (p *lfu) Victim() (uint64, bool) {
+	if len(p.idx) == 0 {
+		return 0, false
+	}
+	if _, ok := p.freq[p.min]; !ok {
+		// Min frequency list was removed, so find new minimum.
+		p.min = 0
+		for f := range p.freq {
+			if p.min == 0 || f < p.min {
+				p.min = f
+			}
+		}
+	}
+	return p.freq[p.min].Back().Value.(*lfuItem).hkey, true
+}
+
+func (p *lfu) Reset() {
+	for k := range p.idx {
+		delete(p.idx, k)
+	}
+	for k := range p.freq {
+		delete(p.freq, k)
+	}
+	p.min = 0
+}
+
+func (p *lfu) list(freq uint64) *list.List {
+	l, ok := p.freq[freq]
+	if !ok {
+		l = list.New()
+		p.freq[freq] = l
+	}
+	return l
+}
+
+func (p *lfu) unlink(itm *lfuItem) {
+	l := p.freq[itm.freq]
+	l.Remove(itm.e)
+	if l.Len() == 0 {
+		delete(p.freq, itm.freq)
+		if p.min == itm.freq {
+			p.min++
+		}
+	}
+}
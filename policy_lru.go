@@ -0,0 +1,60 @@
+package ttlcache
+
+import "container/list"
+
+// LRU is a "least recently used" eviction policy.
+type LRU struct{}
+
+func (LRU) Instance(size uint64) Policy {
+	return &lru{
+		ll:  list.New(),
+		idx: make(map[uint64]*list.Element, size),
+	}
+}
+
+type lru struct {
+	ll  *list.List
+	idx map[uint64]*list.Element
+	// Don't move elements on access (FIFO mode).
+	fifo bool
+}
+
+func (p *lru) Add(hkey uint64) {
+	if e, ok := p.idx[hkey]; ok {
+		if !p.fifo {
+			p.ll.MoveToFront(e)
+		}
+		return
+	}
+	p.idx[hkey] = p.ll.PushFront(hkey)
+}
+
+func (p *lru) Touch(hkey uint64) {
+	if p.fifo {
+		return
+	}
+	if e, ok := p.idx[hkey]; ok {
+		p.ll.MoveToFront(e)
+	}
+}
+
+func (p *lru) Remove(hkey uint64) {
+	if e, ok := p.idx[hkey]; ok {
+		p.ll.Remove(e)
+		delete(p.idx, hkey)
+	}
+}
+
+func (p *lru) Victim() (uint64, bool) {
+	if e := p.ll.Back(); e != nil {
+		return e.Value.(uint64), true
+	}
+	return 0, false
+}
+
+func (p *lru) Reset() {
+	p.ll.Init()
+	for k := range p.idx {
+		delete(p.idx, k)
+	}
+}
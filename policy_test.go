@@ -0,0 +1,169 @@
+package ttlcache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/koykov/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	victim := func(t *testing.T, p Policy) uint64 {
+		hkey, ok := p.Victim()
+		assert.True(t, ok)
+		return hkey
+	}
+	t.Run("lru", func(t *testing.T) {
+		p := LRU{}.Instance(4)
+		p.Add(1)
+		p.Add(2)
+		p.Add(3)
+		p.Touch(1)
+		assert.Equal(t, uint64(2), victim(t, p))
+		p.Remove(2)
+		assert.Equal(t, uint64(3), victim(t, p))
+		p.Reset()
+		_, ok := p.Victim()
+		assert.False(t, ok)
+	})
+	t.Run("fifo", func(t *testing.T) {
+		p := FIFO{}.Instance(4)
+		p.Add(1)
+		p.Add(2)
+		p.Touch(1)
+		assert.Equal(t, uint64(1), victim(t, p))
+		p.Remove(1)
+		assert.Equal(t, uint64(2), victim(t, p))
+	})
+	t.Run("lfu", func(t *testing.T) {
+		p := LFU{}.Instance(4)
+		p.Add(1)
+		p.Add(2)
+		p.Add(3)
+		p.Touch(1)
+		p.Touch(1)
+		p.Touch(2)
+		assert.Equal(t, uint64(3), victim(t, p))
+		p.Remove(3)
+		assert.Equal(t, uint64(2), victim(t, p))
+		p.Remove(2)
+		assert.Equal(t, uint64(1), victim(t, p))
+	})
+	t.Run("tinylfu", func(t *testing.T) {
+		p := TinyLFU{Window: 0.5}.Instance(4)
+		a := p.(Admitter)
+		p.Add(1)
+		p.Add(2)
+		p.Add(3)
+		p.Add(4)
+		p.Touch(1)
+		p.Touch(2)
+		// Window: 4, 3; main: 2, 1.
+		cand, ok := a.Candidate()
+		assert.True(t, ok)
+		assert.Equal(t, uint64(3), cand)
+		vict := victim(t, p)
+		assert.Equal(t, uint64(1), vict)
+		assert.False(t, a.Admit(cand, vict))
+		p.Touch(3)
+		p.Touch(3)
+		assert.True(t, a.Admit(cand, vict))
+	})
+	t.Run("tinylfu scan", func(t *testing.T) {
+		cache, err := New[testEntry](&Config[testEntry]{
+			Size:           100,
+			Buckets:        1,
+			Hasher:         testHasher{},
+			TTLInterval:    time.Minute,
+			EvictionPolicy: TinyLFU{},
+		})
+		assert.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, cache.Set(strconv.Itoa(i), testEntry{p: getEntryBody(i)}))
+		}
+		for j := 0; j < 5; j++ {
+			for i := 0; i < 100; i++ {
+				_, _ = cache.Get(strconv.Itoa(i))
+			}
+		}
+		for i := 100; i < 1100; i++ {
+			assert.NoError(t, cache.Set(strconv.Itoa(i), testEntry{p: getEntryBody(i)}))
+		}
+		var hits int
+		for i := 0; i < 100; i++ {
+			if _, err = cache.Get(strconv.Itoa(i)); err == nil {
+				hits++
+			}
+		}
+		assert.GreaterOrEqual(t, hits, 95)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("cache", func(t *testing.T) {
+		cache, err := New[testEntry](&Config[testEntry]{
+			Size:           4,
+			Buckets:        1,
+			Hasher:         testHasher{},
+			TTLInterval:    time.Minute,
+			EvictionPolicy: LRU{},
+		})
+		assert.NoError(t, err)
+		for i := 0; i < 4; i++ {
+			assert.NoError(t, cache.Set(strconv.Itoa(i), testEntry{p: getEntryBody(i)}))
+		}
+		_, err = cache.Get("0")
+		assert.NoError(t, err)
+		assert.NoError(t, cache.Set("4", testEntry{p: getEntryBody(4)}))
+		_, err = cache.Get("1")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = cache.Get("0")
+		assert.NoError(t, err)
+		_, err = cache.Get("4")
+		assert.NoError(t, err)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("cache out of sync", func(t *testing.T) {
+		c, err := New[testEntry](&Config[testEntry]{
+			Size:           4,
+			Buckets:        1,
+			Hasher:         testHasher{},
+			TTLInterval:    time.Minute,
+			EvictionPolicy: LRU{},
+		})
+		assert.NoError(t, err)
+		// Unknown key becomes the first victim.
+		c.(*cache[string, testEntry]).buckets[0].pol.Add(42)
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, c.Set(strconv.Itoa(i), testEntry{p: getEntryBody(i)}))
+		}
+		_, err = c.Get("0")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, 4, c.Len())
+		assert.NoError(t, c.Close())
+	})
+	t.Run("cache expired", func(t *testing.T) {
+		clk := clock.NewClock()
+		cache, err := New[testEntry](&Config[testEntry]{
+			Size:           4,
+			Buckets:        1,
+			Hasher:         testHasher{},
+			TTLInterval:    time.Minute,
+			EvictInterval:  time.Hour,
+			EvictionPolicy: LRU{},
+			Clock:          clk,
+		})
+		assert.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, cache.Set(strconv.Itoa(i), testEntry{p: getEntryBody(i)}))
+		}
+		assert.NoError(t, cache.SetWithTTL("x", testEntry{}, 10*time.Second))
+		clk.Jump(20 * time.Second)
+		// Expired entry must be evicted instead of least recently used one.
+		assert.NoError(t, cache.Set("3", testEntry{p: getEntryBody(3)}))
+		_, err = cache.Get("0")
+		assert.NoError(t, err)
+		assert.Equal(t, 4, cache.Len())
+		assert.NoError(t, cache.Close())
+	})
+}
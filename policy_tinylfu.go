@@ -0,0 +1,97 @@
+package ttlcache
+
+import "container/list"
+
+const defaultTinyLFUWindow = 0.01
+
+// TinyLFU is a W-TinyLFU eviction policy with admission filter.
+//
+// New entries come to the small window LRU. When the bucket is full, the oldest window entry (candidate) competes with
+// the victim of the main LRU space and the winner is chosen by access frequency estimated using count-min sketch.
+// Thus one-off scans can't push out frequently used entries.
+type TinyLFU struct {
+	// Window size ratio relative to the bucket size. 1% by default.
+	Window float64
+}
+
+func (p TinyLFU) Instance(size uint64) Policy {
+	ratio := p.Window
+	if ratio <= 0 || ratio >= 1 {
+		ratio = defaultTinyLFUWindow
+	}
+	wsize := int(float64(size) * ratio)
+	if wsize == 0 {
+		wsize = 1
+	}
+	return &tinyLFU{
+		wsize:  wsize,
+		window: &lru{ll: list.New(), idx: make(map[uint64]*list.Element, wsize)},
+		main:   &lru{ll: list.New(), idx: make(map[uint64]*list.Element, size)},
+		sketch: newCMSketch(size),
+	}
+}
+
+type tinyLFU struct {
+	wsize  int
+	window *lru
+	main   *lru
+	sketch *cmSketch
+}
+
+func (p *tinyLFU) Add(hkey uint64) {
+	p.sketch.add(hkey)
+	if _, ok := p.main.idx[hkey]; ok {
+		p.main.Touch(hkey)
+		return
+	}
+	p.window.Add(hkey)
+	if p.window.ll.Len() > p.wsize {
+		// Bucket has free space, so move window's tail to the main space without competition.
+		tail := p.window.ll.Back().Value.(uint64)
+		p.window.Remove(tail)
+		p.main.Add(tail)
+	}
+}
+
+func (p *tinyLFU) Touch(hkey uint64) {
+	p.sketch.add(hkey)
+	if _, ok := p.window.idx[hkey]; ok {
+		p.window.Touch(hkey)
+		return
+	}
+	p.main.Touch(hkey)
+}
+
+func (p *tinyLFU) Remove(hkey uint64) {
+	p.window.Remove(hkey)
+	p.main.Remove(hkey)
+}
+
+func (p *tinyLFU) Victim() (uint64, bool) {
+	if hkey, ok := p.main.Victim(); ok {
+		return hkey, true
+	}
+	return p.window.Victim()
+}
+
+func (p *tinyLFU) Candidate() (uint64, bool) {
+	if p.window.ll.Len() < p.wsize {
+		return 0, false
+	}
+	return p.window.Victim()
+}
+
+func (p *tinyLFU) Admit(candidate, victim uint64) bool {
+	if p.sketch.estimate(candidate) <= p.sketch.estimate(victim) {
+		return false
+	}
+	p.window.Remove(candidate)
+	p.main.Add(candidate)
+	return true
+}
+
+func (p *tinyLFU) Reset() {
+	p.window.Reset()
+	p.main.Reset()
+	p.sketch.reset()
+}
@@ -0,0 +1,38 @@
+package ttlcache
+
+import "sync/atomic"
+
+// Stats represents in-process cache statistics.
+type Stats struct {
+	// Len is a total number of entries in the cache, including expired but not yet evicted.
+	Len uint64
+	// Size is a configured cache size (see Config.Size). Zero means unlimited cache.
+	Size uint64
+	// FillRatio is a Len to Size ratio. Always zero for unlimited cache.
+	FillRatio float64
+
+	Hits      uint64
+	Misses    uint64
+	Expires   uint64
+	Evictions uint64
+	Overflows uint64
+	// CachedMisses counts hits of negative entries (see SetMissing).
+	CachedMisses uint64
+	// EvictDrops counts eviction events dropped due to full OnEvict queue (see Config.OnEvictQueueSize).
+	EvictDrops uint64
+}
+
+// Bucket-level counters.
+type bucketStats struct {
+	hits, misses, expires, evicts, overflows, cachedMisses, evictDrops atomic.Uint64
+}
+
+func (s *bucketStats) appendTo(dst *Stats) {
+	dst.Hits += s.hits.Load()
+	dst.Misses += s.misses.Load()
+	dst.Expires += s.expires.Load()
+	dst.Evictions += s.evicts.Load()
+	dst.Overflows += s.overflows.Load()
+	dst.CachedMisses += s.cachedMisses.Load()
+	dst.EvictDrops += s.evictDrops.Load()
+}
@@ -0,0 +1,90 @@
+package ttlcache
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Secondary index of the bucket: tag to set of keys.
+type tagIndex map[string]map[uint64]struct{}
+
+func (x *tagIndex) add(hkey uint64, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if *x == nil {
+		*x = make(tagIndex)
+	}
+	for _, tag := range tags {
+		set, ok := (*x)[tag]
+		if !ok {
+			set = make(map[uint64]struct{})
+			(*x)[tag] = set
+		}
+		set[hkey] = struct{}{}
+	}
+}
+
+func (x tagIndex) remove(hkey uint64, tags []string) {
+	for _, tag := range tags {
+		if set, ok := x[tag]; ok {
+			delete(set, hkey)
+			if len(set) == 0 {
+				delete(x, tag)
+			}
+		}
+	}
+}
+
+func (x tagIndex) reset() {
+	for k := range x {
+		delete(x, k)
+	}
+}
+
+// SetWithTags sets the value marked by given tags. All entries with the same tag may be deleted at once using
+// InvalidateTag.
+func (c *cache[K, T]) SetWithTags(key K, value T, tags ...string) error {
+	if err := c.checkCache(cacheStatusActive); err != nil {
+		return err
+	}
+	hkey := c.conf.Hasher.Sum64(key)
+	b := &c.buckets[hkey%uint64(c.conf.Buckets)]
+	return b.setTagged(hkey, key, value, tags)
+}
+
+// InvalidateTag deletes all entries marked by the tag. Returns number of deleted entries.
+func (c *cache[K, T]) InvalidateTag(tag string) (int, error) {
+	if err := c.checkCache(cacheStatusActive); err != nil {
+		return 0, err
+	}
+	var n int
+	for i := 0; i < len(c.buckets); i++ {
+		n += c.buckets[i].invalidate(tag)
+	}
+	return n, nil
+}
+
+// DeletePrefix deletes all entries which keys start with prefix. Requires enabled Config.StoreKeys and keys of string
+// kind. Returns number of deleted entries.
+func (c *cache[K, T]) DeletePrefix(prefix string) (int, error) {
+	if err := c.checkCache(cacheStatusActive); err != nil {
+		return 0, err
+	}
+	if !c.conf.StoreKeys {
+		return 0, ErrNoKeys
+	}
+	if !stringKey[K]() {
+		return 0, ErrKeyKind
+	}
+	var n int
+	for i := 0; i < len(c.buckets); i++ {
+		n += c.buckets[i].deleteFn(func(e *entry[K, T]) bool {
+			if s, ok := any(e.key).(string); ok {
+				return strings.HasPrefix(s, prefix)
+			}
+			return strings.HasPrefix(reflect.ValueOf(e.key).String(), prefix)
+		})
+	}
+	return n, nil
+}
@@ -0,0 +1,47 @@
+package ttlcache
+
+// UpdateFunc makes new value from the old one. Found flag reports whether key exists in the cache. If false returned,
+// the cache stays unchanged.
+type UpdateFunc[T any] func(old T, found bool) (T, bool)
+
+// Update atomically modifies the entry under bucket's write lock. Updated entry keeps its deadline.
+func (c *cache[K, T]) Update(key K, fn UpdateFunc[T]) error {
+	if err := c.checkCache(cacheStatusActive); err != nil {
+		return err
+	}
+	hkey := c.conf.Hasher.Sum64(key)
+	b := &c.buckets[hkey%uint64(c.conf.Buckets)]
+	return b.update(hkey, key, fn)
+}
+
+// SetIfAbsent sets the value only if key doesn't exist in the cache. Returns actual value and true if given value was
+// set.
+func (c *cache[K, T]) SetIfAbsent(key K, value T) (T, bool, error) {
+	var (
+		actual T
+		set    bool
+	)
+	err := c.Update(key, func(old T, found bool) (T, bool) {
+		if found {
+			actual = old
+			return old, false
+		}
+		actual, set = value, true
+		return value, true
+	})
+	if err != nil {
+		return c.null, false, err
+	}
+	return actual, set, nil
+}
+
+// CompareAndSwap atomically replaces the value of the key with new if current value is equal to old. Reports whether
+// swap happened.
+func CompareAndSwap[K comparable, T comparable](c KCache[K, T], key K, old, new T) (bool, error) {
+	var swapped bool
+	err := c.Update(key, func(cur T, found bool) (T, bool) {
+		swapped = found && cur == old
+		return new, swapped
+	})
+	return swapped && err == nil, err
+}
@@ -0,0 +1,68 @@
+package ttlcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdate(t *testing.T) {
+	newCache := func(t *testing.T) Cache[int] {
+		cache, err := New[int](&Config[int]{
+			Buckets:     4,
+			Hasher:      testHasher{},
+			TTLInterval: time.Minute,
+		})
+		assert.NoError(t, err)
+		return cache
+	}
+	t.Run("update", func(t *testing.T) {
+		cache := newCache(t)
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := cache.Update("counter", func(old int, _ bool) (int, bool) {
+					return old + 1, true
+				})
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		x, err := cache.Get("counter")
+		assert.NoError(t, err)
+		assert.Equal(t, 100, x)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("set if absent", func(t *testing.T) {
+		cache := newCache(t)
+		x, ok, err := cache.SetIfAbsent("foo", 1)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 1, x)
+		x, ok, err = cache.SetIfAbsent("foo", 2)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 1, x)
+		assert.NoError(t, cache.Close())
+	})
+	t.Run("compare and swap", func(t *testing.T) {
+		cache := newCache(t)
+		ok, err := CompareAndSwap(cache, "foo", 0, 1)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.NoError(t, cache.Set("foo", 1))
+		ok, err = CompareAndSwap(cache, "foo", 2, 3)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		ok, err = CompareAndSwap(cache, "foo", 1, 3)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		x, _ := cache.Get("foo")
+		assert.Equal(t, 3, x)
+		assert.NoError(t, cache.Close())
+	})
+}